@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Nagios plugin exit codes
+const (
+	CheckOK       = 0
+	CheckWarning  = 1
+	CheckCritical = 2
+	CheckUnknown  = 3
+)
+
+type CheckCmd struct {
+	DashboardArgs `embed:""`
+}
+
+// CheckResult aggregates the state of the monitors of several dashboards
+type CheckResult struct {
+	Ok     int
+	Warn   int
+	Ko     int
+	Down   []string
+	Warned []string
+	Errs   []error
+}
+
+// Add counts the monitors of the report, prefixing their names with the dashboard when several dashboards are checked
+func (c *CheckResult) Add(report Report, prefix bool) {
+	for _, group := range report.Groups {
+		for _, monitor := range group.Monitors {
+			name := monitor.Name
+			if prefix {
+				name = fmt.Sprintf("%s/%s", report.Dashboard, name)
+			}
+			switch monitor.GlobalState {
+			case KO:
+				c.Ko++
+				c.Down = append(c.Down, name)
+			case Warn:
+				c.Warn++
+				c.Warned = append(c.Warned, name)
+			default:
+				c.Ok++
+			}
+		}
+	}
+}
+
+// Code returns the Nagios exit code of the check.
+// A KO monitor takes precedence over a dashboard that could not be fetched
+func (c *CheckResult) Code() int {
+	if c.Ko > 0 {
+		return CheckCritical
+	}
+	if len(c.Errs) > 0 {
+		return CheckUnknown
+	}
+	if c.Warn > 0 {
+		return CheckWarning
+	}
+	return CheckOK
+}
+
+func (c *CheckResult) String() string {
+	var summary string
+	switch c.Code() {
+	case CheckCritical:
+		summary = fmt.Sprintf("CRITICAL - %d down: %s", c.Ko, strings.Join(c.Down, ", "))
+	case CheckUnknown:
+		summary = fmt.Sprintf("UNKNOWN - %s", strings.ReplaceAll(errors.Join(c.Errs...).Error(), "\n", ", "))
+	case CheckWarning:
+		summary = fmt.Sprintf("WARNING - %d warn: %s", c.Warn, strings.Join(c.Warned, ", "))
+	default:
+		summary = fmt.Sprintf("OK - %d up", c.Ok)
+	}
+	return fmt.Sprintf("%s %s | ok=%d warn=%d ko=%d", strings.ToUpper(APP_NAME), summary, c.Ok, c.Warn, c.Ko)
+}
+
+func (cmd *CheckCmd) Run(config *Config) error {
+	// The status filter only applies to the display, every monitor must be counted
+	config.KeepAll()
+
+	result := CheckResult{}
+	if !CheckAvailability(config.Url, config.HTTP.GetClient()) {
+		result.Errs = append(result.Errs, fmt.Errorf("not connected to kuma"))
+	} else if dashboards, err := cmd.Dashboards(*config); err != nil {
+		result.Errs = append(result.Errs, err)
+	} else {
+		for _, fetched := range FetchAll(*config, dashboards) {
+			if fetched.Err != nil {
+				result.Errs = append(result.Errs, fmt.Errorf("%s: %s", fetched.Dashboard, fetched.Err))
+				continue
+			}
+			result.Add(fetched.Report(*config), len(dashboards) > 1)
+		}
+	}
+
+	fmt.Println(result.String())
+	os.Exit(result.Code())
+	return nil
+}
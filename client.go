@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type HTTPConfig struct {
+	Timeout    time.Duration     `help:"Timeout of each request to kuma" default:"10s"`
+	Retries    int               `help:"Number of retries of the GET requests on network errors and 5xx responses" default:"2"`
+	Backoff    time.Duration     `help:"Delay before the first retry, doubled at each retry" default:"500ms"`
+	Proxy      string            `help:"HTTP(S) proxy URL (default to the HTTP_PROXY/HTTPS_PROXY environment variables)"`
+	CaBundle   string            `help:"PEM bundle of the CA used to verify the kuma certificate, in addition to the system ones" type:"path"`
+	ClientCert string            `help:"PEM client certificate used to authenticate to kuma" type:"path"`
+	ClientKey  string            `help:"PEM key of the client certificate" type:"path"`
+	Insecure   bool              `help:"Skip the verification of the kuma certificate" default:"false"`
+	Header     map[string]string `help:"Extra headers sent with each request (Name=value)"`
+	BasicAuth  string            `help:"Basic auth credentials sent with each request (user:password)"`
+	Client     *http.Client      `kong:"-"`
+	// Transport is shared with the requests made to the other services (notifications, webhooks)
+	Transport *http.Transport `kong:"-"`
+}
+
+// NewClient builds the HTTP client used for every request made to kuma, and the transport shared with the requests
+// made to the other services: they go through the same proxy and trust the same CA, without the kuma credentials
+func (c *HTTPConfig) NewClient() (*http.Client, error) {
+	var errs []error
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if c.Proxy != "" {
+		proxy, err := url.Parse(c.Proxy)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid proxy url (%s): %s", c.Proxy, err))
+		} else {
+			transport.Proxy = http.ProxyURL(proxy)
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.Insecure,
+	}
+	if c.CaBundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		bundle, err := os.ReadFile(c.CaBundle)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to read CA bundle (%s): %s", c.CaBundle, err))
+		} else if !pool.AppendCertsFromPEM(bundle) {
+			errs = append(errs, fmt.Errorf("no certificate found in CA bundle (%s)", c.CaBundle))
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.ClientCert != "" || c.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to load client certificate (%s, %s): %s", c.ClientCert, c.ClientKey, err))
+		} else {
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if c.BasicAuth != "" && !strings.Contains(c.BasicAuth, ":") {
+		errs = append(errs, fmt.Errorf("invalid basic auth credentials: expected user:password"))
+	}
+	if c.Retries < 0 {
+		errs = append(errs, fmt.Errorf("invalid retries count (%d): must be positive", c.Retries))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	c.Transport = transport.Clone()
+	c.Transport.TLSClientConfig.Certificates = nil
+	return &http.Client{
+		Transport: &RetryTransport{
+			Base:      transport,
+			Headers:   c.Header,
+			BasicAuth: c.BasicAuth,
+			Retries:   c.Retries,
+			Backoff:   c.Backoff,
+			Timeout:   c.Timeout,
+		},
+	}, nil
+}
+
+// GetClient returns the configured client, or the default one if the configuration has not been validated
+func (c *HTTPConfig) GetClient() *http.Client {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
+
+// GetTransport returns the transport shared with the other services, or the default one if the configuration
+// has not been validated
+func (c *HTTPConfig) GetTransport() http.RoundTripper {
+	if c.Transport == nil {
+		return http.DefaultTransport
+	}
+	return c.Transport
+}
+
+// RetryTransport adds the configured headers to each request, and retries the GET and HEAD requests failing
+// because of a network error or a 5xx response, with an exponential backoff. The other requests, like the
+// socket.io messages or the webhooks, are not idempotent and are sent once
+type RetryTransport struct {
+	Base      http.RoundTripper
+	Headers   map[string]string
+	BasicAuth string
+	Retries   int
+	Backoff   time.Duration
+	Timeout   time.Duration
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		r := req.Clone(req.Context())
+		if req.Body != nil && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+		for name, value := range t.Headers {
+			r.Header.Set(name, value)
+		}
+		if t.BasicAuth != "" {
+			user, password, _ := strings.Cut(t.BasicAuth, ":")
+			r.SetBasicAuth(user, password)
+		}
+
+		resp, err := t.attempt(r)
+		retryable := (err != nil || resp.StatusCode >= 500) && idempotent(req)
+		if !retryable || attempt >= t.Retries || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(t.Backoff << attempt):
+		}
+	}
+}
+
+// idempotent returns whether the request can be sent again without side effect, an empty method meaning GET
+func idempotent(req *http.Request) bool {
+	return req.Method == "" || req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+// attempt sends the request, the timeout covers the whole attempt, including the reading of the response body
+func (t *RetryTransport) attempt(req *http.Request) (*http.Response, error) {
+	if t.Timeout <= 0 {
+		return t.Base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.Timeout)
+	resp, err := t.Base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{
+		ReadCloser: resp.Body,
+		cancel:     cancel,
+	}
+	return resp, nil
+}
+
+// cancelBody releases the context of the attempt once the response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		method   string
+		attempts int32
+	}{
+		{http.MethodGet, 3},
+		{http.MethodHead, 3},
+		{http.MethodPost, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: &RetryTransport{Base: http.DefaultTransport, Retries: 2, Backoff: time.Millisecond}}
+			req, err := http.NewRequest(test.method, server.URL, strings.NewReader("40"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Body.Close()
+			if attempts.Load() != test.attempts {
+				t.Errorf("%d attempts, want %d", attempts.Load(), test.attempts)
+			}
+		})
+	}
+}
+
+func TestHTTPConfigTransport(t *testing.T) {
+	config := HTTPConfig{Proxy: "http://proxy.example.com:3128", Insecure: true, Header: map[string]string{"X-Token": "secret"}}
+	if _, err := config.NewClient(); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "https://discord.com/api/webhooks/1/token", nil)
+	proxy, err := config.GetTransport().(*http.Transport).Proxy(req)
+	if err != nil || proxy == nil || proxy.String() != "http://proxy.example.com:3128" {
+		t.Errorf("proxy = %v (%v), want the configured one", proxy, err)
+	}
+	if !config.Transport.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("the shared transport does not skip the verification of the certificates")
+	}
+
+	// The kuma headers are not sent to the other services
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer server.Close()
+	direct := HTTPConfig{Header: map[string]string{"X-Token": "secret"}, BasicAuth: "user:password"}
+	if _, err := direct.NewClient(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := (&http.Client{Transport: direct.GetTransport()}).Post(server.URL, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if header.Get("X-Token") != "" || header.Get("Authorization") != "" {
+		t.Errorf("kuma credentials sent to another service: %v", header)
+	}
+
+	if transport := (&HTTPConfig{}).GetTransport(); transport != http.DefaultTransport {
+		t.Errorf("GetTransport() = %v before validation, want the default transport", transport)
+	}
+}
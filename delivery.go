@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Delivery is the result of the notifications sent to a URL
+type Delivery struct {
+	Url    string
+	Sent   int
+	Errors []error
+}
+
+func (d Delivery) Failed() bool {
+	return len(d.Errors) > 0
+}
+
+// Deliveries accumulates the results of the notifications over the dashboards, per URL
+type Deliveries []Delivery
+
+// Add merges the results with the ones of the same URL
+func (d *Deliveries) Add(results ...Delivery) {
+	for _, result := range results {
+		found := false
+		for i := range *d {
+			if (*d)[i].Url == result.Url {
+				(*d)[i].Sent += result.Sent
+				(*d)[i].Errors = append((*d)[i].Errors, result.Errors...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			*d = append(*d, result)
+		}
+	}
+}
+
+func (d Deliveries) Failed() bool {
+	for _, delivery := range d {
+		if delivery.Failed() {
+			return true
+		}
+	}
+	return false
+}
+
+// Sent returns the number of messages sent to every URL
+func (d Deliveries) Sent() int {
+	sent := 0
+	for _, delivery := range d {
+		sent += delivery.Sent
+	}
+	return sent
+}
+
+// Summary returns the errors of each URL, followed by a line summarizing the delivery of the notifications
+func (d Deliveries) Summary() string {
+	sb := strings.Builder{}
+	sent := 0
+	urls := 0
+	failed := 0
+	for _, delivery := range d {
+		sent += delivery.Sent
+		// The errors not bound to a URL come from the configuration of the notifications
+		if delivery.Url != "" {
+			urls++
+		}
+		if !delivery.Failed() {
+			continue
+		}
+		failed++
+		for _, err := range delivery.Errors {
+			if delivery.Url == "" {
+				sb.WriteString(fmt.Sprintf("notification failed: %s\n", err))
+			} else {
+				sb.WriteString(fmt.Sprintf("notification to %s failed: %s\n", RedactUrl(delivery.Url), err))
+			}
+		}
+	}
+	sb.WriteString(fmt.Sprintf("notifications: %d messages sent to %d URLs", sent, urls))
+	if failed > 0 {
+		sb.WriteString(fmt.Sprintf(", %d failed", failed))
+	}
+	return sb.String()
+}
+
+// RedactUrl keeps the scheme and the host of the URL, the credentials and tokens it holds being removed
+func RedactUrl(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil || u.Scheme == "" {
+		return "invalid url"
+	}
+	return fmt.Sprintf("%s://%s", u.Scheme, u.Host)
+}
+
+// permanentError is a failure on which retrying is pointless, like a rejected token or a deleted webhook
+type permanentError struct {
+	error
+}
+
+func (e permanentError) Unwrap() error {
+	return e.error
+}
+
+// statusError is a response of a service with an unexpected HTTP status
+type statusError struct {
+	error
+	StatusCode int
+}
+
+func (e statusError) Unwrap() error {
+	return e.error
+}
+
+// printedStatus matches the HTTP statuses as printed in the errors of the notification services
+// (shoutrrr formats them as "status code 404 Not Found" or "got HTTP 404 Not Found")
+var printedStatus = regexp.MustCompile(`(?:status code|got HTTP) (\d{3})\b`)
+
+// transientStatus returns whether a request answered with the status may succeed if retried:
+// the server errors, the timeouts (408) and the rate limits (429)
+func transientStatus(code int) bool {
+	return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
+
+// isTransient returns whether the delivery may succeed if retried. The network errors are transient,
+// the other responses depend on their status
+func isTransient(err error) bool {
+	var permanent permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	var status statusError
+	if errors.As(err, &status) {
+		return transientStatus(status.StatusCode)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	// The services of shoutrrr only report the status in the text of their errors
+	for _, match := range printedStatus.FindAllStringSubmatch(err.Error(), -1) {
+		code, _ := strconv.Atoi(match[1])
+		if !transientStatus(code) {
+			return false
+		}
+	}
+	return true
+}
+
+// retry calls send until it succeeds, fails permanently, or the retries are exhausted, with an exponential backoff
+func retry(retries int, backoff time.Duration, send func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := send()
+		if err == nil || attempt >= retries || !isTransient(err) {
+			return err
+		}
+		time.Sleep(backoff << attempt)
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+const DiscoverAll = "all"
+
+type DiscoverConfig struct {
+	Discover      bool     `help:"Discover the status pages available on kuma instead of using the dashboard arguments (implied by \"all\")" default:"false"`
+	Username      string   `help:"Kuma username used to list the status pages"`
+	Password      string   `help:"Kuma password used to list the status pages"`
+	DiscoverPages []string `help:"Status pages used when they cannot be discovered"`
+}
+
+// Dashboards returns the dashboards to parse: the discovered status pages when "all" or --discover is used,
+// the arguments otherwise.
+// The status pages are listed using the kuma API when credentials are provided, then using the sitemap,
+// and finally using the configured list. If everything fails, the arguments are used as is.
+func (d *DashboardArgs) Dashboards(config Config) ([]string, error) {
+	if !config.DiscoverConfig.Discover && !(len(d.DashboardPage) == 1 && d.DashboardPage[0] == DiscoverAll) {
+		return d.DashboardPage, nil
+	}
+
+	var errs []error
+	if config.DiscoverConfig.Username != "" {
+		pages, err := DiscoverFromAPI(config)
+		if err == nil && len(pages) > 0 {
+			return pages, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("no status page found")
+		}
+		err = fmt.Errorf("unable to list the status pages using the API: %w", err)
+		// Credentials are configured, so the fallback is worth a warning
+		fmt.Fprintln(os.Stderr, err)
+		errs = append(errs, err)
+	}
+
+	pages, err := DiscoverFromSitemap(config)
+	if err == nil && len(pages) > 0 {
+		return pages, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("no status page found")
+	}
+	err = fmt.Errorf("unable to list the status pages using the sitemap: %w", err)
+	errs = append(errs, err)
+
+	if len(config.DiscoverConfig.DiscoverPages) == 0 && config.DiscoverConfig.Discover {
+		return nil, errors.Join(errs...)
+	}
+	// The discovery failed, so the fallback is worth a warning
+	fmt.Fprintln(os.Stderr, err)
+	if len(config.DiscoverConfig.DiscoverPages) > 0 {
+		return config.DiscoverConfig.DiscoverPages, nil
+	}
+	return d.DashboardPage, nil
+}
+
+type sitemap struct {
+	Urls []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+}
+
+// DiscoverFromSitemap lists the status pages referenced by the sitemap of kuma
+func DiscoverFromSitemap(config Config) ([]string, error) {
+	r, err := config.HTTP.GetClient().Get(fmt.Sprintf("%s/sitemap.xml", config.Url))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", r.StatusCode)
+	}
+
+	content := sitemap{}
+	err = xml.NewDecoder(r.Body).Decode(&content)
+	if err != nil {
+		return nil, err
+	}
+	var pages []string
+	for _, u := range content.Urls {
+		loc, err := url.Parse(strings.TrimSpace(u.Loc))
+		if err != nil {
+			continue
+		}
+		slug, found := strings.CutPrefix(loc.Path, "/status/")
+		if !found || slug == "" || strings.Contains(slug, "/") {
+			continue
+		}
+		pages = appendIfMissing(pages, slug)
+	}
+	return pages, nil
+}
+
+type StatusPageInfo struct {
+	Id        int    `json:"id"`
+	Slug      string `json:"slug"`
+	Title     string `json:"title"`
+	Published bool   `json:"published"`
+}
+
+// DiscoverFromAPI logs in to kuma and returns the published status pages, sorted by title.
+// Kuma only exposes this list over socket.io, so a minimal client using the long-polling transport is used
+func DiscoverFromAPI(config Config) ([]string, error) {
+	socket, err := NewSocketIO(config)
+	if err != nil {
+		return nil, err
+	}
+
+	login := map[string]string{
+		"username": config.DiscoverConfig.Username,
+		"password": config.DiscoverConfig.Password,
+		"token":    "",
+	}
+	err = socket.Emit(1, "login", login)
+	if err != nil {
+		return nil, err
+	}
+
+	var statusPages map[string]StatusPageInfo
+	for i := 0; i < 20 && statusPages == nil; i++ {
+		packets, err := socket.Poll()
+		if err != nil {
+			return nil, err
+		}
+		for _, packet := range packets {
+			switch {
+			case strings.HasPrefix(packet, "431"):
+				var ack []struct {
+					Ok  bool   `json:"ok"`
+					Msg string `json:"msg"`
+				}
+				err = json.Unmarshal([]byte(strings.TrimPrefix(packet, "431")), &ack)
+				if err != nil {
+					return nil, fmt.Errorf("invalid login response: %s", err)
+				}
+				if len(ack) == 0 || !ack[0].Ok {
+					msg := ""
+					if len(ack) > 0 {
+						msg = ack[0].Msg
+					}
+					return nil, fmt.Errorf("login failed: %s", msg)
+				}
+			case strings.HasPrefix(packet, "42"):
+				var event []json.RawMessage
+				err = json.Unmarshal([]byte(strings.TrimPrefix(packet, "42")), &event)
+				if err != nil || len(event) < 2 {
+					continue
+				}
+				var name string
+				if json.Unmarshal(event[0], &name) != nil || name != "statusPageList" {
+					continue
+				}
+				err = json.Unmarshal(event[1], &statusPages)
+				if err != nil {
+					return nil, fmt.Errorf("invalid status page list: %s", err)
+				}
+			}
+		}
+	}
+	if statusPages == nil {
+		return nil, fmt.Errorf("status page list not received")
+	}
+
+	var infos []StatusPageInfo
+	for _, info := range statusPages {
+		if info.Published {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Title < infos[j].Title
+	})
+	var pages []string
+	for _, info := range infos {
+		pages = append(pages, info.Slug)
+	}
+	return pages, nil
+}
+
+// SocketIO is a minimal socket.io v4 client over the engine.io long-polling transport
+type SocketIO struct {
+	client   *http.Client
+	endpoint string
+	sid      string
+}
+
+func NewSocketIO(config Config) (*SocketIO, error) {
+	client := *config.HTTP.GetClient()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	client.Jar = jar
+
+	socket := &SocketIO{
+		client:   &client,
+		endpoint: fmt.Sprintf("%s/socket.io/?EIO=4&transport=polling", config.Url),
+	}
+	packets, err := socket.Poll()
+	if err != nil {
+		return nil, err
+	}
+	if len(packets) == 0 || !strings.HasPrefix(packets[0], "0") {
+		return nil, fmt.Errorf("invalid socket.io handshake")
+	}
+	handshake := struct {
+		Sid string `json:"sid"`
+	}{}
+	err = json.Unmarshal([]byte(strings.TrimPrefix(packets[0], "0")), &handshake)
+	if err != nil {
+		return nil, fmt.Errorf("invalid socket.io handshake: %s", err)
+	}
+	socket.sid = handshake.Sid
+
+	// Connect to the default namespace
+	err = socket.Send("40")
+	if err != nil {
+		return nil, err
+	}
+	return socket, nil
+}
+
+func (s *SocketIO) url() string {
+	if s.sid == "" {
+		return s.endpoint
+	}
+	return fmt.Sprintf("%s&sid=%s", s.endpoint, url.QueryEscape(s.sid))
+}
+
+// Poll returns the pending packets, answering to the pings of the server
+func (s *SocketIO) Poll() ([]string, error) {
+	r, err := s.client.Get(s.url())
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("socket.io: unexpected status code %d: %s", r.StatusCode, body)
+	}
+
+	packets := strings.Split(string(body), "\x1e")
+	for _, packet := range packets {
+		if packet == "2" {
+			err = s.Send("3")
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return packets, nil
+}
+
+func (s *SocketIO) Send(packet string) error {
+	r, err := s.client.Post(s.url(), "text/plain;charset=UTF-8", strings.NewReader(packet))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(r.Body)
+		return fmt.Errorf("socket.io: unexpected status code %d: %s", r.StatusCode, body)
+	}
+	return nil
+}
+
+// Emit sends an event, the acknowledgement is received as a "43<ackId>" packet
+func (s *SocketIO) Emit(ackId int, event string, args ...interface{}) error {
+	payload, err := json.Marshal(append([]interface{}{event}, args...))
+	if err != nil {
+		return err
+	}
+	return s.Send(fmt.Sprintf("42%d%s", ackId, payload))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// FetchResult holds the raw content of a dashboard fetched from kuma, or the error that prevented it
+type FetchResult struct {
+	Dashboard  string
+	Order      []Group
+	HeartBeats HeartBeatList
+	Incident   *Incident
+	// Warnings lists the monitors whose heartbeats could not be fully interpreted
+	Warnings []string
+	Err      error
+}
+
+// Report analyzes the fetched dashboard
+func (r *FetchResult) Report(config Config) Report {
+	report := Parse(config, r.Order, r.HeartBeats, r.Dashboard)
+	report.Incident = r.Incident
+	report.Warnings = r.Warnings
+	return report
+}
+
+// FetchAll fetches the dashboards in parallel, at most config.Concurrency at a time.
+// The results are returned in the same order as the dashboards
+func FetchAll(config Config, dashboards []string) []FetchResult {
+	concurrency := config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]FetchResult, len(dashboards))
+	semaphore := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i, dash := range dashboards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			results[i] = FetchDashboard(config, dash)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// FetchDashboard fetches the groups, the monitors and the incident of the dashboard from kuma
+func FetchDashboard(config Config, dash string) FetchResult {
+	result := FetchResult{
+		Dashboard: dash,
+	}
+	statusPage, err := GetStatusPage(dash, config.Url, config.HTTP.GetClient())
+	if err != nil {
+		result.Err = fmt.Errorf("Dashboard title unavailable: %s", err)
+		return result
+	}
+	titles, order := statusPage.GetTitleDict()
+
+	dashboard, warnings, err := GetDashboard(dash, titles, config)
+	if err != nil {
+		result.Err = fmt.Errorf("Dashboard unavailable: %s", err)
+		return result
+	}
+	result.Order = order
+	result.HeartBeats = dashboard
+	result.Incident = statusPage.Incident
+	result.Warnings = warnings
+	return result
+}
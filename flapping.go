@@ -0,0 +1,59 @@
+package main
+
+import "fmt"
+
+type FlappingConfig struct {
+	Threshold int            `help:"Number of state changes over the beats making a monitor flapping (0 to disable)" default:"0"`
+	Override  map[string]int `help:"Threshold per monitor (prefix with \"re:\" to match using regexes), 0 to disable the detection"`
+	TreatAs   string         `help:"State of a flapping monitor for the global state (warn,ko)" default:"warn" enum:"warn,ko"`
+	overrides PatternValues[int]
+}
+
+// Compile parses the overrides
+func (f *FlappingConfig) Compile() error {
+	overrides, err := CompilePatterns(f.Override)
+	if err != nil {
+		return fmt.Errorf("invalid flapping override: %s", err)
+	}
+	f.overrides = overrides
+	return nil
+}
+
+// GetThreshold returns the threshold applying to the monitor
+func (f *FlappingConfig) GetThreshold(name string) int {
+	if threshold, ok := f.overrides.Get(name); ok {
+		return threshold
+	}
+	return f.Threshold
+}
+
+// State returns the state a flapping monitor is treated as for the global state
+func (f *FlappingConfig) State() State {
+	if f.TreatAs == "ko" {
+		return KO
+	}
+	return Warn
+}
+
+// StateChanges counts the transitions between up and down beats, the beats under maintenance being skipped
+func (m *Monitor) StateChanges() int {
+	var up []bool
+	for _, state := range m.states() {
+		if state != Maintenance {
+			up = append(up, state == OK)
+		}
+	}
+	changes := 0
+	for i := 1; i < len(up); i++ {
+		if up[i] != up[i-1] {
+			changes++
+		}
+	}
+	return changes
+}
+
+// IsFlapping returns whether the state of the monitor changed at least as many times as the threshold
+func (m *Monitor) IsFlapping(f FlappingConfig) bool {
+	threshold := f.GetThreshold(m.Name)
+	return threshold > 0 && m.StateChanges() >= threshold
+}
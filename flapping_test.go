@@ -0,0 +1,105 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestStateChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		states []State
+		want   int
+	}{
+		{"no beat", nil, 0},
+		{"stable", []State{OK, OK, OK}, 0},
+		{"single blip", []State{OK, KO, OK}, 2},
+		{"alternating", []State{OK, KO, OK, KO, OK, KO}, 5},
+		{"warnings are down beats", []State{OK, Warn, KO, OK}, 2},
+		{"pending beats are down beats", []State{OK, Pending, OK}, 2},
+		{"maintenance is skipped", []State{OK, Maintenance, OK, Maintenance, KO}, 1},
+		{"unknown beats are skipped", []State{KO, Unknown, KO, Unknown, OK}, 1},
+	}
+	start := time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			monitor := Monitor{Status: beats(start, test.states...)}
+			if changes := monitor.StateChanges(); changes != test.want {
+				t.Errorf("StateChanges() = %d, want %d", changes, test.want)
+			}
+		})
+	}
+}
+
+func TestIsFlapping(t *testing.T) {
+	flapping := FlappingConfig{
+		Threshold: 4,
+		Override:  map[string]int{"api": 2, "re:^batch-": 0},
+	}
+	if err := flapping.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	states := []State{OK, KO, OK, KO, OK}
+	tests := []struct {
+		name   string
+		states []State
+		want   bool
+	}{
+		{"web", states, true},
+		{"web", []State{OK, KO, OK, OK, OK}, false},
+		{"api", []State{OK, KO, OK, OK, OK}, true},
+		{"batch-nightly", states, false},
+		{"nightly-batch", states, true},
+	}
+	start := time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC)
+	for _, test := range tests {
+		monitor := Monitor{Name: test.name, Status: beats(start, test.states...)}
+		if got := monitor.IsFlapping(flapping); got != test.want {
+			t.Errorf("%s %v: IsFlapping() = %v, want %v", test.name, test.states, got, test.want)
+		}
+	}
+
+	if (&Monitor{Status: beats(start, states...)}).IsFlapping(FlappingConfig{}) {
+		t.Errorf("flapping detected while disabled")
+	}
+	if err := (&FlappingConfig{Override: map[string]int{"re:(": 2}}).Compile(); err == nil {
+		t.Errorf("invalid override regex accepted")
+	}
+}
+
+func TestAnalyzeFlapping(t *testing.T) {
+	tests := []struct {
+		name       string
+		states     []State
+		treatAs    string
+		ignored    bool
+		wantLocal  State
+		wantGlobal State
+	}{
+		{"recovered, treated as warn", []State{OK, KO, OK, KO, OK}, "warn", false, Flapping, Warn},
+		{"recovered, treated as ko", []State{OK, KO, OK, KO, OK}, "ko", false, Flapping, KO},
+		{"recovered and ignored", []State{OK, KO, OK, KO, OK}, "ko", true, Flapping, OK},
+		{"down now", []State{KO, OK, KO, OK, KO}, "warn", false, KO, KO},
+		{"warn now", []State{OK, KO, OK, KO, Warn}, "ko", false, Flapping, KO},
+		{"under maintenance", []State{OK, KO, OK, KO, Maintenance}, "ko", false, Maintenance, OK},
+		{"single blip", []State{OK, OK, KO, OK, OK}, "ko", false, Warn, Warn},
+	}
+	start := time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := Config{Flapping: FlappingConfig{Threshold: 3, TreatAs: test.treatAs}}
+			if err := config.Flapping.Compile(); err != nil {
+				t.Fatal(err)
+			}
+			if test.ignored {
+				config.IgnoreConfig.RegexList = []*regexp.Regexp{regexp.MustCompile("^web$")}
+			}
+			monitor := &Monitor{Name: "web", Status: beats(start, test.states...)}
+			local, global := monitor.Analyze(config)
+			if local != test.wantLocal || global != test.wantGlobal {
+				t.Errorf("Analyze() = %v, %v, want %v, %v", local, global, test.wantLocal, test.wantGlobal)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxFailureLength is the number of characters of a failure message kept for display
+const maxFailureLength = 160
+
+// Failure is the last reason given by kuma for a failing beat of a monitor
+type Failure struct {
+	Msg string
+	// Since is the date of the first beat of the failure
+	Since time.Time
+}
+
+func (f *Failure) String() string {
+	if f.Since.IsZero() {
+		return f.Msg
+	}
+	return fmt.Sprintf("%s (since %s)", f.Msg, FormatSince(f.Since, time.Now()))
+}
+
+// isFailing returns whether the beat reports an issue
+func isFailing(state State) bool {
+	return state == KO || state == Warn || state == Pending || state == Ignored
+}
+
+// LastFailure returns the most recent failure message of the monitor, nil if no failing beat has a message
+func (m *Monitor) LastFailure() *Failure {
+	for i := len(m.Status) - 1; i >= 0; i-- {
+		status := m.Status[i]
+		msg := TruncateMessage(status.Msg, maxFailureLength)
+		if !isFailing(status.Status) || msg == "" {
+			continue
+		}
+		// The failure started with the first beat of the consecutive failing beats
+		start := i
+		for start > 0 && isFailing(m.Status[start-1].Status) {
+			start--
+		}
+		return &Failure{
+			Msg:   msg,
+			Since: time.Time(m.Status[start].Date),
+		}
+	}
+	return nil
+}
+
+// TruncateMessage collapses the whitespaces of the message, and truncates it to the given number of characters
+func TruncateMessage(msg string, length int) string {
+	msg = strings.Join(strings.Fields(msg), " ")
+	runes := []rune(msg)
+	if len(runes) <= length {
+		return msg
+	}
+	return strings.TrimSpace(string(runes[:length-1])) + "…"
+}
+
+// FormatSince formats a past date, the day being omitted when it is the current one
+func FormatSince(t time.Time, now time.Time) string {
+	t = t.Local()
+	now = now.Local()
+	if t.Year() == now.Year() && t.YearDay() == now.YearDay() {
+		return t.Format("15:04")
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// Downtime summarizes the outages of the beats of a monitor
+type Downtime struct {
+	// State is the state of the last beat, and Since the date of the first beat of this state
+	State State
+	Since time.Time
+	// Duration is the total time spent down, a down beat lasting until the next beat
+	Duration time.Duration
+	Outages  int
+	Beats    int
+	// Window is the period covered by the beats, 0 when the last beats are used
+	Window time.Duration
+	now    time.Time
+}
+
+// Downtime computes the downtime of the monitor over its beats, the beats with an unknown status are skipped
+func (m *Monitor) Downtime(now time.Time) Downtime {
+	var beats []Status
+	for _, status := range m.Status {
+		if status.Status != Unknown {
+			beats = append(beats, status)
+		}
+	}
+	downtime := Downtime{
+		Beats: len(beats),
+		now:   now,
+	}
+	if len(beats) == 0 {
+		return downtime
+	}
+
+	for i, beat := range beats {
+		if beat.Status != KO {
+			continue
+		}
+		if i == 0 || beats[i-1].Status != KO {
+			downtime.Outages++
+		}
+		end := now
+		if i+1 < len(beats) {
+			end = time.Time(beats[i+1].Date)
+		}
+		if d := end.Sub(time.Time(beat.Date)); d > 0 {
+			downtime.Duration += d
+		}
+	}
+
+	last := len(beats) - 1
+	downtime.State = beats[last].Status
+	start := last
+	for start > 0 && beats[start-1].Status == downtime.State {
+		start--
+	}
+	downtime.Since = time.Time(beats[start].Date)
+	return downtime
+}
+
+// IsDown returns whether the last beat of the monitor is down
+func (d Downtime) IsDown() bool {
+	return d.State == KO
+}
+
+func (d Downtime) String() string {
+	if d.Outages == 0 {
+		return ""
+	}
+	var parts []string
+	if d.IsDown() && !d.Since.IsZero() {
+		parts = append(parts, fmt.Sprintf("down %s", FormatDuration(d.now.Sub(d.Since))))
+		if d.Outages == 1 {
+			// The current outage is the only one
+			return parts[0]
+		}
+	}
+	outages := "outage"
+	if d.Outages > 1 {
+		outages = "outages"
+	}
+	period := fmt.Sprintf("%d beats", d.Beats)
+	if d.Window > 0 {
+		period = FormatWindow(d.Window)
+	}
+	parts = append(parts, fmt.Sprintf("%d %s in last %s (down %s)", d.Outages, outages, period, FormatDuration(d.Duration)))
+	return strings.Join(parts, ", ")
+}
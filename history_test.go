@@ -0,0 +1,139 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// beats builds one beat per minute from start, in the order of the given states
+func beats(start time.Time, states ...State) []Status {
+	var statuses []Status
+	for i, state := range states {
+		statuses = append(statuses, Status{Status: state, Date: StatusTime(start.Add(time.Duration(i) * time.Minute))})
+	}
+	return statuses
+}
+
+func TestDowntime(t *testing.T) {
+	start := time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		states []State
+		// now is the number of minutes after the first beat
+		now      int
+		window   time.Duration
+		want     Downtime
+		wantText string
+	}{
+		{
+			name: "no beat",
+			now:  5,
+		},
+		{
+			name:   "always up",
+			states: []State{OK, OK, OK},
+			now:    3,
+			want:   Downtime{State: OK, Since: start, Beats: 3},
+		},
+		{
+			name:     "down now, the last beat lasting until now",
+			states:   []State{OK, OK, KO, KO},
+			now:      5,
+			want:     Downtime{State: KO, Since: start.Add(2 * time.Minute), Duration: 3 * time.Minute, Outages: 1, Beats: 4},
+			wantText: "down 3m",
+		},
+		{
+			name:     "recovered",
+			states:   []State{OK, KO, KO, OK, OK},
+			now:      5,
+			want:     Downtime{State: OK, Since: start.Add(3 * time.Minute), Duration: 2 * time.Minute, Outages: 1, Beats: 5},
+			wantText: "1 outage in last 5 beats (down 2m)",
+		},
+		{
+			name:     "several outages, down now",
+			states:   []State{KO, OK, Warn, KO, OK, KO},
+			now:      7,
+			want:     Downtime{State: KO, Since: start.Add(5 * time.Minute), Duration: 4 * time.Minute, Outages: 3, Beats: 6},
+			wantText: "down 2m, 3 outages in last 6 beats (down 4m)",
+		},
+		{
+			name:     "unknown beats are skipped",
+			states:   []State{OK, KO, Unknown, KO, OK},
+			now:      5,
+			want:     Downtime{State: OK, Since: start.Add(4 * time.Minute), Duration: 3 * time.Minute, Outages: 1, Beats: 4},
+			wantText: "1 outage in last 4 beats (down 3m)",
+		},
+		{
+			name:     "pending and maintenance are not outages",
+			states:   []State{Pending, Maintenance, KO, Pending, OK},
+			now:      5,
+			want:     Downtime{State: OK, Since: start.Add(4 * time.Minute), Duration: time.Minute, Outages: 1, Beats: 5},
+			wantText: "1 outage in last 5 beats (down 1m)",
+		},
+		{
+			name:     "over a window",
+			states:   []State{KO, OK, KO, OK},
+			now:      4,
+			window:   time.Hour,
+			want:     Downtime{State: OK, Since: start.Add(3 * time.Minute), Duration: 2 * time.Minute, Outages: 2, Beats: 4, Window: time.Hour},
+			wantText: "2 outages in last 1h (down 2m)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := start.Add(time.Duration(test.now) * time.Minute)
+			monitor := Monitor{Status: beats(start, test.states...)}
+			downtime := monitor.Downtime(now)
+			downtime.Window = test.window
+			test.want.now = now
+			if downtime != test.want {
+				t.Errorf("Downtime() = %+v, want %+v", downtime, test.want)
+			}
+			if text := downtime.String(); text != test.wantText {
+				t.Errorf("String() = %q, want %q", text, test.wantText)
+			}
+		})
+	}
+}
+
+func TestIncident(t *testing.T) {
+	created := time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC)
+	updated := StatusTime(created.Add(30 * time.Minute))
+	var zero StatusTime
+	tests := []struct {
+		name      string
+		incident  Incident
+		wantKey   string
+		wantDate  time.Time
+		wantColor string
+	}{
+		{"created", Incident{Id: 3, CreatedDate: StatusTime(created), Style: "danger"}, "3@2024-05-02T14:00:00Z", created, red},
+		{"updated", Incident{Id: 3, CreatedDate: StatusTime(created), LastUpdatedDate: &updated, Style: "warning"}, "3@2024-05-02T14:30:00Z", time.Time(updated), yellow},
+		{"update date not set", Incident{Id: 3, CreatedDate: StatusTime(created), LastUpdatedDate: &zero, Style: "info"}, "3@2024-05-02T14:00:00Z", created, blue},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if key := test.incident.Key(); key != test.wantKey {
+				t.Errorf("Key() = %q, want %q", key, test.wantKey)
+			}
+			if date := test.incident.Date(); !date.Equal(test.wantDate) {
+				t.Errorf("Date() = %s, want %s", date, test.wantDate)
+			}
+			if color := test.incident.NotificationColor(); color != test.wantColor {
+				t.Errorf("NotificationColor() = %q, want %q", color, test.wantColor)
+			}
+		})
+	}
+
+	incident := Incident{Title: "Maintenance", Content: "\n  Database upgrade  \n\n  Back at 15:00\n", CreatedDate: StatusTime(created)}
+	lines := incident.Lines()
+	want := []string{"Maintenance (" + created.Local().Format("2006-01-02 15:04") + ")", "Database upgrade", "Back at 15:00"}
+	if len(lines) != len(want) {
+		t.Fatalf("Lines() = %q, want %q", lines, want)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("Lines()[%d] = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
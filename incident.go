@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Incident is the message posted on a status page
+type Incident struct {
+	Id              int         `json:"id"`
+	Title           string      `json:"title"`
+	Content         string      `json:"content"`
+	Style           string      `json:"style"`
+	CreatedDate     StatusTime  `json:"createdDate"`
+	LastUpdatedDate *StatusTime `json:"lastUpdatedDate"`
+}
+
+// TerminalColor returns the ANSI color name matching the style of the incident
+func (i *Incident) TerminalColor(c Color) string {
+	switch i.Style {
+	case "danger":
+		return c.KoBeat
+	case "warning":
+		return c.WarnBeat
+	case "primary", "info":
+		return "blue"
+	}
+	return "white"
+}
+
+// NotificationColor returns the notification color matching the style of the incident
+func (i *Incident) NotificationColor() string {
+	switch i.Style {
+	case "danger":
+		return red
+	case "warning":
+		return yellow
+	}
+	return blue
+}
+
+// Date returns the date of the last update of the incident
+func (i *Incident) Date() time.Time {
+	if i.LastUpdatedDate != nil && !time.Time(*i.LastUpdatedDate).IsZero() {
+		return time.Time(*i.LastUpdatedDate)
+	}
+	return time.Time(i.CreatedDate)
+}
+
+// Key identifies a version of the incident, it changes each time the incident is updated
+func (i *Incident) Key() string {
+	return fmt.Sprintf("%d@%s", i.Id, i.Date().Format(time.RFC3339))
+}
+
+// Lines returns the title and the content of the incident, line by line
+func (i *Incident) Lines() []string {
+	title := i.Title
+	if date := i.Date(); !date.IsZero() {
+		title = fmt.Sprintf("%s (%s)", title, date.Local().Format("2006-01-02 15:04"))
+	}
+	lines := []string{title}
+	for _, line := range strings.Split(strings.TrimSpace(i.Content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// RenderIncident builds the banner displayed above the groups of the dashboard
+func RenderIncident(config Config, incident *Incident) string {
+	if incident == nil {
+		return ""
+	}
+	color := colors[strings.ToLower(incident.TerminalColor(config.Color))]
+	sb := strings.Builder{}
+	for i, line := range incident.Lines() {
+		if i == 0 {
+			line = fmt.Sprintf("%s %s", config.Symbol.Incident, line)
+		}
+		if config.Xbar {
+			// Pipes are interpreted by xbar as the parameters separator
+			line = strings.ReplaceAll(line, "|", "¦")
+		}
+		sb.WriteString(fmt.Sprintf("\u001B[%dm%s\u001B[0m\n", color, line))
+	}
+	if config.Xbar {
+		sb.WriteString("---\n")
+	}
+	return sb.String()
+}
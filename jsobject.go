@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// JSObjectParser parses a javascript object literal (as inlined by kuma in the status pages) into the
+// equivalent go values: single quoted strings, unquoted keys, undefined values and trailing commas are supported
+type JSObjectParser struct {
+	input string
+	pos   int
+}
+
+// ParseJSObject parses the object literal starting at the beginning of the input.
+// It returns the parsed value and the number of bytes consumed
+func ParseJSObject(input string) (interface{}, int, error) {
+	p := &JSObjectParser{input: input}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, p.pos, err
+	}
+	return value, p.pos, nil
+}
+
+func (p *JSObjectParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid javascript object at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *JSObjectParser) skipSpaces() {
+	for p.pos < len(p.input) {
+		r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+func (p *JSObjectParser) peek() byte {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *JSObjectParser) parseValue() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '{':
+		return p.parseObject()
+	case c == '[':
+		return p.parseArray()
+	case c == '"' || c == '\'' || c == '`':
+		return p.parseString()
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	case c == 0:
+		return nil, p.errorf("unexpected end of input")
+	}
+
+	identifier := p.parseIdentifier()
+	switch identifier {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null", "undefined":
+		return nil, nil
+	case "":
+		return nil, p.errorf("unexpected character %q", p.input[p.pos])
+	}
+	return nil, p.errorf("unsupported identifier %s", identifier)
+}
+
+func (p *JSObjectParser) parseObject() (map[string]interface{}, error) {
+	object := map[string]interface{}{}
+	p.pos++ // {
+	for {
+		c := p.peek()
+		if c == '}' {
+			p.pos++
+			return object, nil
+		}
+
+		var key string
+		if c == '"' || c == '\'' || c == '`' {
+			k, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			key = k
+		} else {
+			key = p.parseIdentifier()
+			if key == "" {
+				return nil, p.errorf("expected object key")
+			}
+		}
+
+		if p.peek() != ':' {
+			return nil, p.errorf("expected ':' after key %s", key)
+		}
+		p.pos++
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		object[key] = value
+
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+		default:
+			return nil, p.errorf("expected ',' or '}' in object")
+		}
+	}
+}
+
+func (p *JSObjectParser) parseArray() ([]interface{}, error) {
+	array := []interface{}{}
+	p.pos++ // [
+	for {
+		if p.peek() == ']' {
+			p.pos++
+			return array, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+func (p *JSObjectParser) parseIdentifier() string {
+	start := p.pos
+	for p.pos < len(p.input) {
+		r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		if !(r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			break
+		}
+		p.pos += size
+	}
+	return p.input[start:p.pos]
+}
+
+func (p *JSObjectParser) parseNumber() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && strings.IndexByte("+-.0123456789eE", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+	value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if err != nil {
+		return 0, p.errorf("invalid number %s", p.input[start:p.pos])
+	}
+	return value, nil
+}
+
+func (p *JSObjectParser) parseString() (string, error) {
+	quote := p.input[p.pos]
+	p.pos++
+	sb := strings.Builder{}
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return sb.String(), nil
+		case c == '\\':
+			p.pos++
+			if p.pos >= len(p.input) {
+				return "", p.errorf("unterminated string")
+			}
+			escaped := p.input[p.pos]
+			p.pos++
+			switch escaped {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'v':
+				sb.WriteByte('\v')
+			case '0':
+				sb.WriteByte(0)
+			case 'x':
+				if p.pos+2 > len(p.input) {
+					return "", p.errorf("invalid hexadecimal escape")
+				}
+				value, err := strconv.ParseUint(p.input[p.pos:p.pos+2], 16, 8)
+				if err != nil {
+					return "", p.errorf("invalid hexadecimal escape")
+				}
+				sb.WriteRune(rune(value))
+				p.pos += 2
+			case 'u':
+				r, err := p.parseUnicodeEscape()
+				if err != nil {
+					return "", err
+				}
+				sb.WriteRune(r)
+			case '\n':
+				// Line continuation
+			default:
+				sb.WriteByte(escaped)
+			}
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// parseUnicodeEscape parses the \uXXXX and \u{XXXXX} escapes, including the UTF-16 surrogate pairs
+func (p *JSObjectParser) parseUnicodeEscape() (rune, error) {
+	if p.pos < len(p.input) && p.input[p.pos] == '{' {
+		end := strings.IndexByte(p.input[p.pos:], '}')
+		if end < 0 {
+			return 0, p.errorf("invalid unicode escape")
+		}
+		value, err := strconv.ParseUint(p.input[p.pos+1:p.pos+end], 16, 32)
+		if err != nil {
+			return 0, p.errorf("invalid unicode escape")
+		}
+		p.pos += end + 1
+		return rune(value), nil
+	}
+
+	readHex := func() (rune, error) {
+		if p.pos+4 > len(p.input) {
+			return 0, p.errorf("invalid unicode escape")
+		}
+		value, err := strconv.ParseUint(p.input[p.pos:p.pos+4], 16, 16)
+		if err != nil {
+			return 0, p.errorf("invalid unicode escape")
+		}
+		p.pos += 4
+		return rune(value), nil
+	}
+	r, err := readHex()
+	if err != nil {
+		return 0, err
+	}
+	// A high surrogate is only paired with the escape following it when it is a low surrogate,
+	// otherwise the escape is parsed on its own
+	if r >= 0xD800 && r < 0xDC00 && strings.HasPrefix(p.input[p.pos:], "\\u") {
+		next := p.pos
+		p.pos += 2
+		low, err := readHex()
+		if err == nil && low >= 0xDC00 && low <= 0xDFFF {
+			return utf16.DecodeRune(r, low), nil
+		}
+		p.pos = next
+	}
+	if utf16.IsSurrogate(r) {
+		return unicode.ReplacementChar, nil
+	}
+	return r, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseJSObject(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  interface{}
+	}{
+		{"double quotes", `"a\"b"`, `a"b`},
+		{"single quotes", `'it\'s'`, "it's"},
+		{"backticks", "`a`", "a"},
+		{"control escapes", `"\n\r\t\b\f\v\0"`, "\n\r\t\b\f\v\x00"},
+		{"unknown escape", `"\/\q"`, "/q"},
+		{"line continuation", "\"a\\\nb\"", "ab"},
+		{"hexadecimal escape", `"\x41\xe9"`, "Aé"},
+		{"unicode escape", `"\u00e9\u4E2D"`, "é中"},
+		{"unicode code point escape", `"\u{1F600}"`, "😀"},
+		{"surrogate pair", `"\ud83d\ude00"`, "😀"},
+		{"lone surrogate", `"\ud83d!"`, "�!"},
+		{"high surrogate before another escape", `"\uD83D\u0041"`, "�A"},
+		{"high surrogate before a high surrogate", `"\uD83D\uD83D\uDE00"`, "�😀"},
+		{"lone low surrogate", `"\uDE00\u0041"`, "�A"},
+		{"reversed surrogates", `"\uDE00\uD83D"`, "��"},
+		{"high surrogate at the end", `"\uD83D"`, "�"},
+		{"raw utf-8", `"中文"`, "中文"},
+		{"object", `{a: 1, 'b': "x", c: undefined, d: [true, false, null,],}`, map[string]interface{}{
+			"a": 1.0,
+			"b": "x",
+			"c": nil,
+			"d": []interface{}{true, false, nil},
+		}},
+		{"numbers", `[-1.5, +2, .5, 1e3]`, []interface{}{-1.5, 2.0, 0.5, 1000.0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, n, err := ParseJSObject(test.input)
+			if err != nil {
+				t.Fatalf("ParseJSObject(%q) failed: %s", test.input, err)
+			}
+			if n != len(test.input) {
+				t.Errorf("ParseJSObject(%q) consumed %d bytes, want %d", test.input, n, len(test.input))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseJSObject(%q) = %#v, want %#v", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseJSObjectRest(t *testing.T) {
+	input := `{a: "}"}; window.other = 1`
+	_, n, err := ParseJSObject(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rest := input[n:]; rest != "; window.other = 1" {
+		t.Errorf("rest = %q", rest)
+	}
+}
+
+func TestParseJSObjectErrors(t *testing.T) {
+	for _, input := range []string{
+		``,
+		`{a 1}`,
+		`{a: 1 b: 2}`,
+		`[1 2]`,
+		`"unterminated`,
+		`"\x4"`,
+		`"\uZZZZ"`,
+		`"\u{110000"`,
+		`"\ud83d\uZZZZ"`,
+		`{a: foo}`,
+	} {
+		if _, _, err := ParseJSObject(input); err == nil {
+			t.Errorf("ParseJSObject(%q) succeeded, want an error", input)
+		}
+	}
+}
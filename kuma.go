@@ -2,13 +2,17 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Titles struct {
@@ -26,7 +30,7 @@ type Titles struct {
 		GoogleAnalyticsId     interface{} `json:"googleAnalyticsId"`
 		ShowCertificateExpiry bool        `json:"showCertificateExpiry"`
 	} `json:"config"`
-	Incident        []KumaGroup `json:"incident"`
+	Incident        *Incident   `json:"incident"`
 	PublicGroupList []KumaGroup `json:"publicGroupList"`
 	MaintenanceList []KumaGroup `json:"maintenanceList"`
 }
@@ -47,8 +51,8 @@ type MonitorTitle struct {
 	Type      string `json:"type"`
 }
 
-func CheckAvailability(url *url.URL) bool {
-	r, err := http.Head(fmt.Sprintf("%s/dashboard", url))
+func CheckAvailability(url *url.URL, client *http.Client) bool {
+	r, err := client.Head(fmt.Sprintf("%s/dashboard", url))
 	if err != nil {
 		return false
 	}
@@ -60,28 +64,8 @@ func CheckAvailability(url *url.URL) bool {
 	return true
 }
 
-func GetTitleDict(dashboardName string, url *url.URL) (map[string]MonitorTitle, []Group, error) {
-	r, err := http.Get(fmt.Sprintf("%s/status/%s", url, dashboardName))
-	if err != nil {
-		return nil, nil, err
-	}
-	defer r.Body.Close()
-	body, err := io.ReadAll(r.Body)
-	re := regexp.MustCompile(`window\.preloadData\s*=\s*(\{.*\});`)
-	matches := re.FindStringSubmatch(string(body))
-	if matches == nil {
-		return nil, nil, fmt.Errorf("unable to get dashboard %s", dashboardName)
-	}
-	if len(matches) < 2 {
-		return nil, nil, fmt.Errorf("unable to get dashboard %s", dashboardName)
-	}
-	content := strings.ReplaceAll(matches[1], "'", "\"")
-	content = strings.ReplaceAll(content, ":undefined,", ":\"undefined\",")
-	titles := Titles{}
-	err = json.Unmarshal([]byte(content), &titles)
-	if err != nil {
-		return nil, nil, err
-	}
+// GetTitleDict returns the monitors of the status page keyed by id, and the groups in display order
+func (titles *Titles) GetTitleDict() (map[string]MonitorTitle, []Group) {
 	monitorTitles := make(map[string]MonitorTitle)
 	var groupOrder []Group
 	for _, group := range titles.MaintenanceList {
@@ -91,13 +75,6 @@ func GetTitleDict(dashboardName string, url *url.URL) (map[string]MonitorTitle,
 			monitorTitles[strconv.Itoa(t.Id)] = t
 		}
 	}
-	for _, group := range titles.Incident {
-		for _, t := range group.MonitorList {
-			t.GroupId = group.Id
-			t.GroupName = strings.TrimSpace(group.Name)
-			monitorTitles[strconv.Itoa(t.Id)] = t
-		}
-	}
 	for _, group := range titles.PublicGroupList {
 		for _, t := range group.MonitorList {
 			t.GroupId = group.Id
@@ -112,47 +89,186 @@ func GetTitleDict(dashboardName string, url *url.URL) (map[string]MonitorTitle,
 	for i := range groupOrder {
 		groupOrder[i].Name = strings.TrimSpace(groupOrder[i].Name)
 	}
-	return monitorTitles, groupOrder, nil
+	return monitorTitles, groupOrder
+}
+
+// statusPageAPI records, per kuma instance, whether the JSON status page API is available
+var statusPageAPI sync.Map
+
+var (
+	errStatusPageAPIUnavailable = errors.New("status page API unavailable")
+	errStatusPageAPINotJSON     = errors.New("status page API did not answer with JSON")
+)
+
+// GetStatusPage fetches the configuration of the status page using the JSON API of kuma.
+// The older releases do not provide this API, in which case the data inlined in the HTML page is used.
+// The API is only remembered as unavailable when the route is unknown (a 404, or the web application served with
+// a 200), so that a transient error page does not make the instance be detected as an older release
+func GetStatusPage(dashboardName string, url *url.URL, client *http.Client) (Titles, error) {
+	available, known := statusPageAPI.Load(url.String())
+	if !known || available.(bool) {
+		titles, err := getStatusPageFromAPI(dashboardName, url, client)
+		switch {
+		case err == nil:
+			statusPageAPI.Store(url.String(), true)
+			return titles, nil
+		case errors.Is(err, errStatusPageAPIUnavailable):
+			statusPageAPI.Store(url.String(), false)
+		case !errors.Is(err, errStatusPageAPINotJSON):
+			return titles, err
+		}
+	}
+	return getStatusPageFromHTML(dashboardName, url, client)
+}
+
+func getStatusPageFromAPI(dashboardName string, url *url.URL, client *http.Client) (Titles, error) {
+	r, err := client.Get(fmt.Sprintf("%s/api/status-page/%s", url, dashboardName))
+	if err != nil {
+		return Titles{}, err
+	}
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return Titles{}, err
+	}
+
+	// The older releases do not know the route, answering 404 or falling back to the web application,
+	// any other answer that is not JSON may be temporary
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if r.StatusCode == http.StatusNotFound || r.StatusCode == http.StatusOK {
+			return Titles{}, errStatusPageAPIUnavailable
+		}
+		return Titles{}, errStatusPageAPINotJSON
+	}
+	if r.StatusCode != http.StatusOK {
+		apiError := struct {
+			Msg string `json:"msg"`
+		}{}
+		_ = json.Unmarshal(body, &apiError)
+		return Titles{}, fmt.Errorf("unable to get dashboard %s: %d %s", dashboardName, r.StatusCode, apiError.Msg)
+	}
+
+	titles := Titles{}
+	err = json.Unmarshal(body, &titles)
+	if err != nil {
+		return Titles{}, err
+	}
+	return titles, nil
 }
 
-func GetDashboard(dashboardName string, titles map[string]MonitorTitle, config Config) (HeartBeatList, error) {
-	r, err := http.Get(fmt.Sprintf("%s/api/status-page/heartbeat/%s", config.Url, dashboardName))
+func getStatusPageFromHTML(dashboardName string, url *url.URL, client *http.Client) (Titles, error) {
+	r, err := client.Get(fmt.Sprintf("%s/status/%s", url, dashboardName))
 	if err != nil {
-		return HeartBeatList{}, err
+		return Titles{}, err
 	}
 	defer r.Body.Close()
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		return HeartBeatList{}, err
+		return Titles{}, err
+	}
+
+	re := regexp.MustCompile(`window\.preloadData\s*=\s*`)
+	loc := re.FindIndex(body)
+	if loc == nil {
+		return Titles{}, fmt.Errorf("unable to get dashboard %s", dashboardName)
+	}
+	preloadData, _, err := ParseJSObject(string(body[loc[1]:]))
+	if err != nil {
+		return Titles{}, fmt.Errorf("unable to get dashboard %s: %s", dashboardName, err)
+	}
+
+	// The parsed object is converted back to JSON to be decoded as the API response
+	content, err := json.Marshal(preloadData)
+	if err != nil {
+		return Titles{}, err
+	}
+	titles := Titles{}
+	err = json.Unmarshal(content, &titles)
+	if err != nil {
+		return Titles{}, err
+	}
+	return titles, nil
+}
+
+// DetectRelease returns the configured release of kuma, or the one detected from the availability
+// of the status page API
+func DetectRelease(config Config) KumaRelease {
+	switch config.KumaRelease {
+	case "legacy":
+		return KumaLegacy
+	case "current":
+		return KumaCurrent
+	}
+	if available, known := statusPageAPI.Load(config.Url.String()); known && !available.(bool) {
+		return KumaLegacy
+	}
+	return KumaCurrent
+}
+
+// GetDashboard fetches the heartbeats of the monitors of the dashboard.
+// The statuses are interpreted according to the release of kuma, the unsupported ones are reported as warnings
+func GetDashboard(dashboardName string, titles map[string]MonitorTitle, config Config) (HeartBeatList, []string, error) {
+	r, err := config.HTTP.GetClient().Get(fmt.Sprintf("%s/api/status-page/heartbeat/%s", config.Url, dashboardName))
+	if err != nil {
+		return HeartBeatList{}, nil, err
+	}
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return HeartBeatList{}, nil, err
 	}
 
 	dashboard := Dashboard{}
 
 	err = json.Unmarshal(body, &dashboard)
 	if err != nil {
-		return HeartBeatList{}, err
+		return HeartBeatList{}, nil, err
 	}
 
+	release := DetectRelease(config)
+	now := time.Now()
+	var warnings []string
 	hblist := make(HeartBeatList)
 	for monitorId, status := range dashboard.HeartBeat {
 		if IsInList(titles[monitorId].GroupName, config.IgnoreConfig.IgnoreSection, config.IgnoreConfig.RegexSectionList) {
 			continue
 		}
+		var unknown []int
+		for i := range status {
+			if status[i].Code < 0 {
+				continue
+			}
+			status[i].Status = release.State(status[i].Code)
+			if status[i].Status == Unknown {
+				unknown = appendIfMissing(unknown, status[i].Code)
+			}
+		}
+		if len(unknown) > 0 {
+			name := strings.TrimSpace(titles[monitorId].Name)
+			if name == "" {
+				name = fmt.Sprintf("#%s", monitorId)
+			}
+			warnings = append(warnings, fmt.Sprintf("%s: unknown heartbeat status %s", name, strings.Trim(fmt.Sprint(unknown), "[]")))
+		}
 		group := Group{
 			Id:   titles[monitorId].GroupId,
 			Name: titles[monitorId].GroupName,
 		}
-		if len(status) > config.Beats {
+		if config.Window > 0 {
+			status = FilterWindow(status, now.Add(-config.Window))
+		} else if len(status) > config.Beats {
 			status = status[len(status)-config.Beats:]
 		}
 		monitor := &Monitor{
 			Id:     monitorId,
 			Name:   strings.TrimSpace(titles[monitorId].Name),
 			Status: status,
+			Uptime: dashboard.Uptime.Get(monitorId),
 		}
 		hblist[group] = append(hblist[group], monitor)
 	}
-	return hblist, nil
+	sort.Strings(warnings)
+	return hblist, warnings, nil
 }
 
 func appendIfMissing[T comparable](slice []T, elem T) []T {
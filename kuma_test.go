@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDetectRelease(t *testing.T) {
+	const page = `<html><script>window.preloadData = {config: {slug: 'prod', title: 'Production'}, incident: null, publicGroupList: []};</script></html>`
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        KumaRelease
+		// calls is the number of requests to the API for two status pages, none once it is known to be missing
+		calls int32
+	}{
+		{"api", http.StatusOK, "application/json; charset=utf-8", `{"config": {"slug": "prod", "title": "Production"}}`, KumaCurrent, 2},
+		{"unknown route", http.StatusNotFound, "text/html; charset=utf-8", "Cannot GET /api/status-page/prod", KumaLegacy, 1},
+		{"web application fallback", http.StatusOK, "text/html; charset=utf-8", page, KumaLegacy, 1},
+		{"proxy error page", http.StatusBadGateway, "text/html", "<html>502 Bad Gateway</html>", KumaCurrent, 2},
+		{"proxy login page", http.StatusUnauthorized, "text/html", "<html>Sign in</html>", KumaCurrent, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls atomic.Int32
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/status-page/prod", func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.Header().Set("Content-Type", test.contentType)
+				w.WriteHeader(test.status)
+				_, _ = w.Write([]byte(test.body))
+			})
+			mux.HandleFunc("/status/prod", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				_, _ = w.Write([]byte(page))
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+			kumaUrl, _ := url.Parse(server.URL)
+
+			for i := 0; i < 2; i++ {
+				titles, err := GetStatusPage("prod", kumaUrl, server.Client())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if titles.Config.Title != "Production" {
+					t.Errorf("title = %q, want Production", titles.Config.Title)
+				}
+			}
+			if release := DetectRelease(Config{Url: kumaUrl, KumaRelease: "auto"}); release != test.want {
+				t.Errorf("DetectRelease() = %v, want %v", release, test.want)
+			}
+			if calls.Load() != test.calls {
+				t.Errorf("API called %d times, want %d", calls.Load(), test.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+type LatencyConfig struct {
+	Show      bool          `help:"Show the latency sparkline and statistics of the monitors" default:"false"`
+	Threshold time.Duration `help:"Latency above which a monitor is considered as slow, and promoted to warn (0 to disable)" default:"0"`
+	Last      int           `help:"Number of last pings above the threshold making a monitor slow, whatever the p95" default:"3"`
+}
+
+// LatencyStats summarizes the pings of a monitor, in milliseconds
+type LatencyStats struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Avg   float64 `json:"avg"`
+	P95   float64 `json:"p95"`
+	Max   float64 `json:"max"`
+}
+
+func (l LatencyStats) String() string {
+	return fmt.Sprintf("min %s avg %s p95 %s max %s", FormatMs(l.Min), FormatMs(l.Avg), FormatMs(l.P95), FormatMs(l.Max))
+}
+
+// FormatMs formats a latency given in milliseconds
+func FormatMs(ms float64) string {
+	return fmt.Sprintf("%.0fms", ms)
+}
+
+// pings returns the response times of the beats, the beats without ping (down, maintenance...) are skipped
+func (m *Monitor) pings() []float64 {
+	var pings []float64
+	for _, status := range m.Status {
+		if status.Ping > 0 {
+			pings = append(pings, status.Ping)
+		}
+	}
+	return pings
+}
+
+// Latency computes the statistics of the pings of the monitor, the count is 0 when no ping is available
+func (m *Monitor) Latency() LatencyStats {
+	pings := m.pings()
+	if len(pings) == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]float64{}, pings...)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, ping := range sorted {
+		sum += ping
+	}
+	// Nearest-rank percentile
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	return LatencyStats{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Avg:   sum / float64(len(sorted)),
+		P95:   sorted[rank],
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// IsSlow returns whether the p95 of the pings, or each of the last pings, exceeds the threshold
+func (m *Monitor) IsSlow(lc LatencyConfig) bool {
+	if lc.Threshold <= 0 {
+		return false
+	}
+	threshold := float64(lc.Threshold) / float64(time.Millisecond)
+	pings := m.pings()
+	if len(pings) == 0 {
+		return false
+	}
+	if m.Latency().P95 > threshold {
+		return true
+	}
+	if lc.Last <= 0 || len(pings) < lc.Last {
+		return false
+	}
+	for _, ping := range pings[len(pings)-lc.Last:] {
+		if ping <= threshold {
+			return false
+		}
+	}
+	return true
+}
+
+// Sparkline renders the pings of the monitor, one character per displayed beat so that it lines up with the beats,
+// the resampled buckets using the average ping of their beats. The beats without ping are rendered as a space
+func (m *Monitor) Sparkline(c Config) string {
+	beats := m.displayedBeats(c)
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, status := range beats {
+		if status.Ping > 0 {
+			low = math.Min(low, status.Ping)
+			high = math.Max(high, status.Ping)
+		}
+	}
+	sb := strings.Builder{}
+	for _, status := range beats {
+		if status.Ping <= 0 {
+			sb.WriteRune(' ')
+			continue
+		}
+		level := len(sparkLevels) / 2
+		if high > low {
+			level = int((status.Ping - low) / (high - low) * float64(len(sparkLevels)-1))
+		}
+		sb.WriteRune(sparkLevels[level])
+	}
+	return sb.String()
+}
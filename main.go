@@ -7,12 +7,13 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/alecthomas/kong"
-	"github.com/rivo/uniseg"
 	"gopkg.in/yaml.v3"
 )
 
@@ -25,25 +26,35 @@ var (
 const APP_NAME = "kumago"
 
 type Color struct {
-	IgnoredBeat string `yaml:"ko" default:"cyan" help:"Terminal color used to display an ignored beat (ANSI color name)"`
-	WarnBeat    string `yaml:"ko" default:"yellow" help:"Terminal color used to display a warn beat (ANSI color name)"`
-	OkBeat      string `yaml:"ko" default:"green" help:"Terminal color used to display an OK beat (ANSI color name)"`
-	KoBeat      string `yaml:"ko" default:"red" help:"Terminal color used to display a KO beat (ANSI color name)"`
+	IgnoredBeat     string `yaml:"ko" default:"cyan" help:"Terminal color used to display an ignored beat (ANSI color name)"`
+	WarnBeat        string `yaml:"ko" default:"yellow" help:"Terminal color used to display a warn beat (ANSI color name)"`
+	OkBeat          string `yaml:"ko" default:"green" help:"Terminal color used to display an OK beat (ANSI color name)"`
+	KoBeat          string `yaml:"ko" default:"red" help:"Terminal color used to display a KO beat (ANSI color name)"`
+	MaintenanceBeat string `yaml:"maintenance" default:"blue" help:"Terminal color used to display a maintenance beat (ANSI color name)"`
+	UnknownBeat     string `yaml:"unknown" default:"white" help:"Terminal color used to display a beat with an unknown status (ANSI color name)"`
+	Flapping        string `yaml:"flapping" default:"magenta" help:"Terminal color used to display a flapping monitor (ANSI color name)"`
+	NoDataBeat      string `yaml:"nodata" default:"black" help:"Terminal color used to display a period without beat (ANSI color name)"`
 }
 
 type Symbol struct {
 	Term string `yaml:"ko" default:"█" help:"Symbol used to display a beat"`
 
-	Warn    string `yaml:"warn" default:"🤔" help:"Emoji used to indicate a warning state"`
-	Ignored string `yaml:"ignored" default:"💤" help:"Emoji used to indicate a warning state"`
-	Ok      string `yaml:"ok" default:"👌" help:"Emoji used to indicate an OK state"`
-	Ko      string `yaml:"ko" default:"🔥" help:"Emoji used to indicate a KO state"`
-	Error   string `yaml:"ko" default:"🏩" help:"Emoji used to indicate an error state"`
+	Warn        string `yaml:"warn" default:"🤔" help:"Emoji used to indicate a warning state"`
+	Ignored     string `yaml:"ignored" default:"💤" help:"Emoji used to indicate a warning state"`
+	Ok          string `yaml:"ok" default:"👌" help:"Emoji used to indicate an OK state"`
+	Ko          string `yaml:"ko" default:"🔥" help:"Emoji used to indicate a KO state"`
+	Error       string `yaml:"ko" default:"🏩" help:"Emoji used to indicate an error state"`
+	Incident    string `yaml:"incident" default:"📢" help:"Emoji used to indicate an incident posted on the status page"`
+	Maintenance string `yaml:"maintenance" default:"🚧" help:"Emoji used to indicate a monitor under maintenance"`
+	Flapping    string `yaml:"flapping" default:"🔀" help:"Emoji used to indicate a flapping monitor"`
 
-	IgnoredBeatEmoji string `yaml:"ko" default:"🟦" help:"Emoji used to display a warn beat"`
-	WarnBeatEmoji    string `yaml:"ko" default:"🟧" help:"Emoji used to display a warn beat"`
-	OkBeatEmoji      string `yaml:"ko" default:"🟩" help:"Emoji used to display an OK beat"`
-	KoBeatEmoji      string `yaml:"ko" default:"🟥" help:"Emoji used to display a KO beat"`
+	IgnoredBeatEmoji     string `yaml:"ko" default:"🟦" help:"Emoji used to display a warn beat"`
+	WarnBeatEmoji        string `yaml:"ko" default:"🟧" help:"Emoji used to display a warn beat"`
+	OkBeatEmoji          string `yaml:"ko" default:"🟩" help:"Emoji used to display an OK beat"`
+	KoBeatEmoji          string `yaml:"ko" default:"🟥" help:"Emoji used to display a KO beat"`
+	MaintenanceBeatEmoji string `yaml:"maintenance" default:"🟪" help:"Emoji used to display a maintenance beat"`
+	UnknownBeatEmoji     string `yaml:"unknown" default:"⬜" help:"Emoji used to display a beat with an unknown status"`
+	NoDataBeatEmoji      string `yaml:"nodata" default:"⬛" help:"Emoji used to display a period without beat"`
 }
 
 func (s *Symbol) Get(state State) string {
@@ -58,6 +69,10 @@ func (s *Symbol) Get(state State) string {
 		return s.Ok
 	case Ignored:
 		return s.Ignored
+	case Maintenance:
+		return s.Maintenance
+	case Flapping:
+		return s.Flapping
 	}
 	return " "
 }
@@ -70,10 +85,16 @@ func (s *Symbol) GetBeat(state State, c Color) string {
 		return fmt.Sprintf("\u001B[%dm%s\u001B[0m", colors[strings.ToLower(c.OkBeat)], s.Term)
 	case KO:
 		return fmt.Sprintf("\u001B[%dm%s\u001B[0m", colors[strings.ToLower(c.KoBeat)], s.Term)
-	case Warn:
+	case Warn, Pending:
 		return fmt.Sprintf("\u001B[%dm%s\u001B[0m", colors[strings.ToLower(c.WarnBeat)], s.Term)
 	case Ignored:
 		return fmt.Sprintf("\u001B[%dm%s\u001B[0m", colors[strings.ToLower(c.IgnoredBeat)], s.Term)
+	case Maintenance:
+		return fmt.Sprintf("\u001B[%dm%s\u001B[0m", colors[strings.ToLower(c.MaintenanceBeat)], s.Term)
+	case Unknown:
+		return fmt.Sprintf("\u001B[%dm%s\u001B[0m", colors[strings.ToLower(c.UnknownBeat)], s.Term)
+	case noData:
+		return fmt.Sprintf("\u001B[%dm%s\u001B[0m", colors[strings.ToLower(c.NoDataBeat)], s.Term)
 	}
 	return " "
 }
@@ -86,29 +107,73 @@ func (s *Symbol) GetBeatEmoji(state State) string {
 		return s.Ok
 	case KO:
 		return s.KoBeatEmoji
-	case Warn:
+	case Warn, Pending:
 		return s.WarnBeatEmoji
 	case Ignored:
 		return s.IgnoredBeatEmoji
+	case Maintenance:
+		return s.MaintenanceBeatEmoji
+	case Unknown:
+		return s.UnknownBeatEmoji
+	case noData:
+		return s.NoDataBeatEmoji
 	}
 	return " "
 }
 
 type Config struct {
-	Status        []string     `help:"Status to display (OK,KO,Warn)" default:"KO,Warn"`
-	Xbar          bool         `help:"Enable Xbar mode" default:"false"`
-	Notify        bool         `help:"Send notification" default:"false"`
-	Url           *url.URL     `help:"Kuma URL" default:"" short:"u"`
-	DashboardPage []string     `help:"Dashboard pages to parse" default:"all" arg:""`
-	IgnoreConfig  IgnoreConfig `help:"Ignore list" embed:""`
-	NotifyUrl     []string     `help:"Notification URL" default:""`
-	Beats         int          `help:"Show/hide heartbeat" default:"50"`
-	Beat          bool         `help:"Show/hide heartbeat" negatable:"" default:"true"`
-	BeatEmoji     bool         `help:"Use emoji in beats" default:"false"`
-	Emoji         bool         `help:"Show synthesis emoji" default:"true" negatable:""`
-	Color         Color        `help:"Color" default:"" embed:"" prefix:"color-"`
-	Symbol        Symbol       `help:"Symbol" default:"" embed:"" prefix:"icon-"`
-	Version       bool         `help:"Show version" default:"false"`
+	Status         []string       `help:"Status to display (OK,KO,Warn,Ignored,Maintenance,Flapping,all)" default:"KO,Warn"`
+	Xbar           bool           `help:"Enable Xbar mode" default:"false"`
+	Output         string         `help:"Output format (text,json,prometheus)" default:"text" enum:"text,json,prometheus" short:"o"`
+	Notify         bool           `help:"Send notification" default:"false"`
+	Url            *url.URL       `help:"Kuma URL" default:"" short:"u"`
+	IgnoreConfig   IgnoreConfig   `help:"Ignore list" embed:""`
+	NotifyUrl      []string       `help:"Notification URL" default:""`
+	NotifyOnChange bool           `help:"Only notify the monitors whose state changed since the last run" default:"false"`
+	NotifyRetries  int            `help:"Number of retries of the notifications failing with a transient error" default:"2"`
+	NotifyBackoff  time.Duration  `help:"Delay before the first retry of a notification, doubled at each retry" default:"1s"`
+	NotifyExitCode int            `help:"Exit code when a notification could not be delivered (0 to ignore the failures)" default:"1"`
+	StateFile      string         `help:"File used to persist the state of the monitors between runs (default to the user cache directory)" type:"path"`
+	Beats          int            `help:"Show/hide heartbeat" default:"50"`
+	Window         time.Duration  `help:"Only analyze and display the beats of this period (e.g. 6h) instead of the last --beats ones" default:"0"`
+	WindowBuckets  int            `help:"Resample the beats of the window into this number of columns (0 to display every beat)" default:"0"`
+	Uptime         bool           `help:"Show the 24h (and 30d when available) uptime of the monitors" default:"false"`
+	MinUptime      float64        `help:"Only show the monitors whose 24h uptime is below this percentage, whatever their status" default:"0"`
+	Sort           string         `help:"Order of the monitors in their group (name,uptime)" default:"name" enum:"name,uptime"`
+	Latency        LatencyConfig  `help:"Latency" embed:"" prefix:"latency-"`
+	Flapping       FlappingConfig `help:"Flapping detection" embed:"" prefix:"flapping-"`
+	Webhook        WebhookConfig  `help:"JSON webhooks" embed:"" prefix:"webhook-"`
+	Templates      TemplateConfig `help:"Notification templates" embed:"" prefix:"notify-"`
+	Beat           bool           `help:"Show/hide heartbeat" negatable:"" default:"true"`
+	BeatEmoji      bool           `help:"Use emoji in beats" default:"false"`
+	Emoji          bool           `help:"Show synthesis emoji" default:"true" negatable:""`
+	Color          Color          `help:"Color" default:"" embed:"" prefix:"color-"`
+	Symbol         Symbol         `help:"Symbol" default:"" embed:"" prefix:"icon-"`
+	DiscoverConfig DiscoverConfig `help:"Status pages discovery" embed:""`
+	HTTP           HTTPConfig     `help:"HTTP client" embed:"" prefix:"http-"`
+	Concurrency    int            `help:"Maximum number of dashboards fetched in parallel" default:"4"`
+	KumaRelease    string         `help:"Release of kuma, used to interpret the heartbeat statuses (auto,legacy,current)" default:"auto" enum:"auto,legacy,current"`
+	Version        bool           `help:"Show version" default:"false"`
+
+	Show  ShowCmd  `cmd:"" default:"withargs" help:"Display the state of the dashboards (default)"`
+	Check CheckCmd `cmd:"" help:"Check the state of the dashboards, Nagios plugin style"`
+	Watch WatchCmd `cmd:"" help:"Periodically refresh the state of the dashboards in the terminal"`
+	Serve ServeCmd `cmd:"" help:"Serve the state of the dashboards over HTTP"`
+}
+
+type DashboardArgs struct {
+	DashboardPage []string `help:"Dashboard pages to parse (\"all\" to discover the status pages of kuma)" default:"all" arg:""`
+}
+
+type ShowCmd struct {
+	DashboardArgs `embed:""`
+}
+
+func (c *Config) StateFilePath() (string, error) {
+	if c.StateFile != "" {
+		return c.StateFile, nil
+	}
+	return DefaultStateFilePath()
 }
 
 func (c *Config) GetVersion() string {
@@ -124,6 +189,7 @@ type IgnoreConfig struct {
 	RegexSectionList  []*regexp.Regexp `kong:"-"`
 	OnlyLastRegexList []*regexp.Regexp `kong:"-"`
 	HiddenRegexList   []*regexp.Regexp `kong:"-"`
+	Rules             RulesConfig      `embed:"" prefix:"rule-"`
 }
 
 func (c *Config) KeepOk() bool {
@@ -135,6 +201,21 @@ func (c *Config) KeepIgnored() bool {
 
 }
 
+func (c *Config) KeepMaintenance() bool {
+	return ContainsStringFold(c.Status, "all") || ContainsStringFold(c.Status, "maintenance")
+}
+
+// KeepFlapping keeps the flapping monitors when asked, or when the state they are treated as is kept
+func (c *Config) KeepFlapping() bool {
+	if ContainsStringFold(c.Status, "all") || ContainsStringFold(c.Status, "flapping") {
+		return true
+	}
+	if c.Flapping.State() == KO {
+		return c.KeepKo()
+	}
+	return c.KeepWarn()
+}
+
 func (c *Config) KeepWarn() bool {
 	return ContainsStringFold(c.Status, "all") || ContainsStringFold(c.Status, "warn")
 }
@@ -142,8 +223,14 @@ func (c *Config) KeepKo() bool {
 	return ContainsStringFold(c.Status, "all") || ContainsStringFold(c.Status, "ko")
 }
 
+// KeepAll disables the filters, so that every monitor is reported
+func (c *Config) KeepAll() {
+	c.Status = []string{"all"}
+	c.MinUptime = 0
+}
+
 func (c *Config) Keep(localStatus State) bool {
-	return !((localStatus == KO && !c.KeepKo()) || ((localStatus == Warn || localStatus == WarnOk) && !c.KeepWarn()) || (localStatus == OK && !c.KeepOk()) || (localStatus == Ignored && !c.KeepIgnored()))
+	return !((localStatus == KO && !c.KeepKo()) || ((localStatus == Warn || localStatus == WarnOk) && !c.KeepWarn()) || (localStatus == OK && !c.KeepOk()) || (localStatus == Ignored && !c.KeepIgnored()) || (localStatus == Maintenance && !c.KeepMaintenance()) || (localStatus == Flapping && !c.KeepFlapping()))
 }
 
 func (c *Config) Validate() error {
@@ -214,10 +301,39 @@ func (c *Config) Validate() error {
 	}
 	c.IgnoreConfig.Onlylast = onlyLastList
 
-	_, err := StringToRune(c.Symbol.Term)
+	if c.Window < 0 {
+		errs = append(errs, fmt.Errorf("invalid window (%s): must be positive", c.Window))
+	}
+	if c.WindowBuckets < 0 || (c.WindowBuckets > 0 && c.Window == 0) {
+		errs = append(errs, fmt.Errorf("invalid window buckets (%d): must be positive, and used with --window", c.WindowBuckets))
+	} else if c.Window > 0 && c.Window < time.Duration(c.WindowBuckets) {
+		// The buckets would last less than a nanosecond
+		errs = append(errs, fmt.Errorf("invalid window buckets (%d): more buckets than nanoseconds in the window (%s)", c.WindowBuckets, c.Window))
+	}
+
+	err := c.Flapping.Compile()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	err = c.IgnoreConfig.Rules.Compile()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	err = c.Templates.Compile(c.Symbol)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	_, err = StringToRune(c.Symbol.Term)
 	if err != nil {
 		errs = append(errs, fmt.Errorf("invalid term icon (%s): %s", c.Symbol.Term, err))
 	}
+
+	c.HTTP.Client, err = c.HTTP.NewClient()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	c.Webhook.transport = c.HTTP.GetTransport()
 	return errors.Join(errs...)
 }
 
@@ -245,13 +361,9 @@ func main() {
 		kong.Configuration(YAML, configSearchDir...),
 		kong.DefaultEnvars(strings.ToUpper(APP_NAME)),
 	}
-	_ = kong.Parse(&config, kongOptions...)
+	ctx := kong.Parse(&config, kongOptions...)
 	if config.Version {
-		fmt.Printf(config.GetVersion())
-		return
-	}
-	if !CheckAvailability(config.Url) {
-		Error(fmt.Errorf("Dashboard unavailable: not connected to kuma"), config)
+		fmt.Print(config.GetVersion())
 		return
 	}
 
@@ -260,37 +372,130 @@ func main() {
 		config.Symbol.Ko = ""
 		config.Symbol.Ok = ""
 	}
-	for _, dash := range config.DashboardPage {
-		titles, order, err := GetTitleDict(dash, config.Url)
+	err = ctx.Run(&config)
+	if err != nil {
+		Error(err, config)
+	}
+}
+
+func (s *ShowCmd) Run(config *Config) error {
+	if !CheckAvailability(config.Url, config.HTTP.GetClient()) {
+		return fmt.Errorf("Dashboard unavailable: not connected to kuma")
+	}
+
+	var states *StateFile
+	if config.Notify && config.NotifyOnChange {
+		path, err := config.StateFilePath()
 		if err != nil {
-			Error(fmt.Errorf("Dashboard title unavailable: %s", err), config)
-			return
+			return err
 		}
-
-		dashboard, err := GetDashboard(dash, titles, config)
+		states, err = LoadStateFile(path)
 		if err != nil {
-			Error(fmt.Errorf("Dashboard unavailable: %s", err), config)
-			return
+			return err
 		}
+	}
 
-		//sort.Slice(groups, func(i, j int) bool {
-		//	return groups[i].Name < groups[j].Name
-		//})
+	if config.Output == OutputPrometheus {
+		// The metrics cover every monitor whatever the status filter
+		config.KeepAll()
+	}
+	var metrics []JSONReport
+	fetchErrors := map[string]int{}
 
-		content, globalState, _ := Parse(config, order, dashboard, dash)
+	dashboards, err := s.Dashboards(*config)
+	if err != nil {
+		return err
+	}
 
-		PrintContent(content)
-		if config.Notify {
-			err = Notify(content, config)
+	failed := false
+	var deliveries Deliveries
+	for _, result := range FetchAll(*config, dashboards) {
+		dash := result.Dashboard
+		if result.Err != nil {
+			failed = true
+			fetchErrors[dash]++
+			switch config.Output {
+			case OutputPrometheus:
+				metrics = append(metrics, JSONReport{Dashboard: dash, Error: result.Err.Error()})
+			case OutputJSON:
+				err := RenderJSON(os.Stdout, Report{Dashboard: dash}, result.Err)
+				if err != nil {
+					fmt.Println(err)
+				}
+			default:
+				PrintContent(RenderError(*config, dash, result.Err))
+			}
+			continue
+		}
+		report := result.Report(*config)
+		content := RenderText(*config, report)
+
+		switch config.Output {
+		case OutputPrometheus:
+			metrics = append(metrics, NewJSONReport(report))
+		case OutputJSON:
+			err := RenderJSON(os.Stdout, report, nil)
 			if err != nil {
 				fmt.Println(err)
 			}
+		default:
+			PrintContent(content)
 		}
+		if config.Notify {
+			// The changes are computed on every monitor, whatever the status filter,
+			// otherwise a recovered monitor would never be seen
+			allConfig := *config
+			allConfig.KeepAll()
+			full := result.Report(allConfig)
+			var changes *Report
+			var previous map[string]MonitorState
+			var previousIncident string
+			if states != nil {
+				previous, previousIncident = states.Dashboards[dash], states.Incidents[dash]
+				changed := states.Update(full, time.Now())
+				changes = &changed
+				content = RenderText(*config, changed)
+			}
+			var results Deliveries
+			if len(config.NotifyUrl) > 0 || len(config.Webhook.Url) == 0 {
+				results.Add(Notify(content, *config)...)
+			}
+			// Without any change, the webhooks are only called when the changes are not tracked
+			if len(config.Webhook.Url) > 0 && (changes == nil || len(changes.Groups) > 0 || changes.Incident != nil) {
+				results.Add(config.Webhook.Send(NewWebhookPayload(full, changes))...)
+			}
+			if states != nil && results.Failed() {
+				// The changes are notified again on the next run
+				states.Restore(dash, previous, previousIncident)
+			}
+			deliveries.Add(results...)
+		}
+	}
 
-		if globalState == KO {
+	if config.Output == OutputPrometheus {
+		err := WritePrometheus(os.Stdout, metrics, fetchErrors)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
 
+	if states != nil {
+		err := states.Save()
+		if err != nil {
+			return err
 		}
 	}
+	// Nothing is reported when no change was notified
+	if deliveries.Sent() > 0 || deliveries.Failed() {
+		fmt.Fprintln(os.Stderr, deliveries.Summary())
+	}
+	if failed {
+		os.Exit(1)
+	}
+	if deliveries.Failed() && config.NotifyExitCode != 0 {
+		os.Exit(config.NotifyExitCode)
+	}
+	return nil
 }
 
 func PrintContent(content Content) {
@@ -300,26 +505,18 @@ func PrintContent(content Content) {
 func countChar(s string, c Config) int {
 
 	count := 0
-	var (
-		ok      rune
-		ko      rune
-		warn    rune
-		ignored rune
-	)
 	if !c.Beat {
 		return 0
 	}
 	if c.BeatEmoji && c.Emoji {
-		r, _ := StringToRune(c.Symbol.OkBeatEmoji)
-		ok = r
-		r, _ = StringToRune(c.Symbol.KoBeatEmoji)
-		ko = r
-		r, _ = StringToRune(c.Symbol.WarnBeatEmoji)
-		warn = r
-		r, _ = StringToRune(c.Symbol.IgnoredBeatEmoji)
-		ignored = r
+		beats := map[rune]struct{}{}
+		for _, emoji := range []string{c.Symbol.OkBeatEmoji, c.Symbol.KoBeatEmoji, c.Symbol.WarnBeatEmoji, c.Symbol.IgnoredBeatEmoji,
+			c.Symbol.MaintenanceBeatEmoji, c.Symbol.UnknownBeatEmoji, c.Symbol.NoDataBeatEmoji} {
+			r, _ := StringToRune(emoji)
+			beats[r] = struct{}{}
+		}
 		for _, r := range s {
-			if r == ok || r == ko || r == warn || r == ignored {
+			if _, ok := beats[r]; ok {
 				count++
 			}
 		}
@@ -367,7 +564,12 @@ func YAML(r io.Reader) (kong.Resolver, error) {
 		}
 		path = append(path, flag.Name)
 		path = strings.Split(strings.Join(path, "-"), "-")
-		return find(config, path), nil
+		value := find(config, path)
+		// YAML decodes the round numbers as integers, which kong cannot assign to a float
+		if i, ok := value.(int); ok && flag.Target.Kind() == reflect.Float64 {
+			return float64(i), nil
+		}
+		return value, nil
 	}), nil
 }
 
@@ -384,149 +586,106 @@ func find(config map[string]interface{}, path []string) interface{} {
 	return config[strings.Join(path, "-")]
 }
 
-func Notify(hb Content, c Config) error {
-	if c.NotifyUrl == nil {
-		return fmt.Errorf("no notify url")
-	}
-
-	err, notifier := NewNotifier(c)
-
-	if err != nil {
-		return err
+// Notify sends the content to the notification URLs, and returns the result of the delivery to each of them
+func Notify(hb Content, c Config) Deliveries {
+	notifier, err := NewNotifier(c)
+	if notifier == nil {
+		return Deliveries{{Errors: []error{err}}}
 	}
-	notifier.Notify(hb, c)
-	return nil
+	// The URLs that cannot be parsed are reported as failed by the notifier, the other ones being notified
+	return notifier.Notify(hb, c)
 }
 
-func Parse(config Config, groups []Group, dashboard HeartBeatList, dashName string) (Content, State, HeartBeatList) {
-
-	globalState := OK
-
-	downList := HeartBeatList{}
-	length := 0
-	for _, monitors := range dashboard {
-		for _, monitor := range monitors {
-			localStatus, _ := monitor.analyzeStatus(config.IgnoreConfig)
-			if !config.Keep(localStatus) || IsInList(monitor.Name, config.IgnoreConfig.Hidden, config.IgnoreConfig.HiddenRegexList) {
-				continue
-			}
-			l := len(monitor.Name)
-			if l > length {
-				length = l
-			}
-		}
-	}
-
-	content := Content{}
-
-	maxWidth := 0
+// Report holds the result of the analysis of a dashboard, independently of the way it is rendered
+type Report struct {
+	Dashboard   string
+	GlobalState State
+	Groups      []ReportGroup
+	Incident    *Incident
+	Warnings    []string
+}
 
-	for _, group := range groups {
-		monitors := dashboard[group]
-		for _, monitor := range monitors {
-			localStatus, _ := monitor.analyzeStatus(config.IgnoreConfig)
-			if !config.Keep(localStatus) || IsInList(monitor.Name, config.IgnoreConfig.Hidden, config.IgnoreConfig.HiddenRegexList) {
-				continue
-			}
+type ReportGroup struct {
+	Group    Group
+	Monitors []ReportMonitor
+}
 
-			l := uniseg.GraphemeClusterCount(removeANSICodes(monitor.Beats(config)))
+type ReportMonitor struct {
+	*Monitor
+	LocalState  State
+	GlobalState State
+	// Slow is set when the latency of the monitor exceeds the configured threshold
+	Slow bool
+	// Change is set when the monitor is reported because its state changed since the last run
+	Change *StateChange
+}
 
-			if l > maxWidth {
-				maxWidth = l
-			}
-		}
+// Parse analyzes the monitors of the dashboard and returns the monitors to display, ordered by group,
+// alongside the global state of the dashboard
+func Parse(config Config, groups []Group, dashboard HeartBeatList, dashName string) Report {
+	report := Report{
+		Dashboard:   dashName,
+		GlobalState: OK,
 	}
 
 	for _, group := range groups {
 		monitors := dashboard[group]
 
-		contentGroup := ParsedGroups{
-			GroupName: group.Name,
+		reportGroup := ReportGroup{
+			Group: group,
 		}
 		sort.Slice(monitors, func(i, j int) bool {
+			if config.Sort == SortUptime && monitors[i].Uptime.Compare(monitors[j].Uptime) != 0 {
+				return monitors[i].Uptime.Compare(monitors[j].Uptime) < 0
+			}
 			return monitors[i].Name < monitors[j].Name
 		})
 
 		for _, monitor := range monitors {
-			var icon string
-			localStatus, globalStatus := monitor.analyzeStatus(config.IgnoreConfig)
-			if !config.Keep(localStatus) || IsInList(monitor.Name, config.IgnoreConfig.Hidden, config.IgnoreConfig.HiddenRegexList) {
-				continue
-			}
-			icon = config.Symbol.Get(localStatus)
-			if localStatus == KO {
-				downList[group] = append(downList[group], monitor)
+			localStatus, globalStatus := monitor.Analyze(config)
+			keep := config.Keep(localStatus)
+			if config.MinUptime > 0 {
+				// The SLA filter replaces the status filter
+				keep = monitor.Uptime.Day != nil && *monitor.Uptime.Day*100 < config.MinUptime
 			}
-			nb := countChar(monitor.Beats(config), config)
-
-			pad := maxWidth - nb
-			if pad < 0 {
-				pad = 0
-			}
-			if config.BeatEmoji && config.Emoji {
-				pad *= 2
-			}
-
-			beats := fmt.Sprintf("%-*s%s ", pad, "", monitor.Beats(config))
-
-			if config.Xbar {
-				beats = fmt.Sprintf("%s | font=\"FiraCode Nerd Font\"\n", beats)
-			} else {
-				beats = fmt.Sprintf("%s\n", beats)
+			if !keep || IsInList(monitor.Name, config.IgnoreConfig.Hidden, config.IgnoreConfig.HiddenRegexList) {
+				continue
 			}
-
-			contentGroup.Monitors = append(contentGroup.Monitors, ParsedMonitor{
-				State:      localStatus,
-				Emoji:      icon,
-				Beats:      beats,
-				EmojiBeats: fmt.Sprintf("%-*s%s \n", pad, "", monitor.EmojiBeats(config)),
-				Name:       monitor.GetName(length, config),
+			reportGroup.Monitors = append(reportGroup.Monitors, ReportMonitor{
+				Monitor:     monitor,
+				LocalState:  localStatus,
+				GlobalState: globalStatus,
+				Slow:        monitor.IsSlow(config.Latency),
 			})
-			if globalState == KO {
+			if report.GlobalState == KO {
 				continue
 			}
 
 			if globalStatus == KO {
-				globalState = KO
+				report.GlobalState = KO
 				continue
 			}
-			if globalStatus == Warn && globalState == OK {
-				globalState = Warn
+			if globalStatus == Warn && report.GlobalState == OK {
+				report.GlobalState = Warn
 				continue
 			}
 		}
-		if contentGroup.IsOK() {
-			contentGroup.GroupName = fmt.Sprintf("\u001B[%dm%s\u001B[0m", colors[config.Color.OkBeat], contentGroup.GroupName)
-		}
-
-		if contentGroup.IsWarn() {
-			contentGroup.GroupName = fmt.Sprintf("\u001B[%dm%s\u001B[0m", colors[config.Color.WarnBeat], contentGroup.GroupName)
-		}
-
-		if contentGroup.IsKO() {
-			contentGroup.GroupName = fmt.Sprintf("\u001B[%dm%s\u001B[0m", colors[config.Color.KoBeat], contentGroup.GroupName)
-		}
-		if len(contentGroup.Monitors) > 0 {
-			content.Content = append(content.Content, contentGroup)
+		if len(reportGroup.Monitors) > 0 {
+			report.Groups = append(report.Groups, reportGroup)
 		}
 	}
-
-	content.Header = dashName
-	if config.Xbar {
-		icon := config.Symbol.Get(globalState)
-
-		content.Header = fmt.Sprintf("%s %s\n---", dashName, icon)
-		content.Footer = "Refresh... | refresh=true"
-	}
-
-	//fmt.Println(content)
-	return content, globalState, downList
+	return report
 }
 
 type Content struct {
-	Header  string
-	Footer  string
-	Content []ParsedGroups
+	Header string
+	// State is the global state of the dashboard
+	State    State
+	Banner   string
+	Footer   string
+	Content  []ParsedGroups
+	Incident *Incident
+	Warnings string
 }
 
 func (c *Content) String() string {
@@ -535,6 +694,7 @@ func (c *Content) String() string {
 		sb.WriteString(c.Header)
 		sb.WriteString("\n")
 	}
+	sb.WriteString(c.Banner)
 	for _, group := range c.Content {
 		sb.WriteString(group.GroupName)
 		sb.WriteString("\n")
@@ -547,6 +707,7 @@ func (c *Content) String() string {
 		sb.WriteString("\n")
 
 	}
+	sb.WriteString(c.Warnings)
 
 	if c.Footer != "" {
 		sb.WriteString(c.Footer)
@@ -562,7 +723,7 @@ type ParsedGroups struct {
 
 func (group ParsedGroups) IsOK() bool {
 	for _, monitor := range group.Monitors {
-		if monitor.State == KO || monitor.State == Warn {
+		if monitor.State == KO || monitor.State == Warn || monitor.State == Flapping {
 			return false
 		}
 	}
@@ -579,12 +740,17 @@ func (group ParsedGroups) IsKO() bool {
 }
 
 func (group ParsedGroups) IsWarn() bool {
+	warn := false
 	for _, monitor := range group.Monitors {
 		if monitor.State == KO || monitor.State == OK {
 			return false
 		}
+		// The monitors under maintenance do not color the group
+		if monitor.State != Maintenance {
+			warn = true
+		}
 	}
-	return true
+	return warn
 }
 
 type ParsedMonitor struct {
@@ -593,6 +759,13 @@ type ParsedMonitor struct {
 	Beats      string
 	EmojiBeats string
 	Name       string
+	Details    string
+	Uptime     string
+	Latency    string
+	Failure    string
+	Downtime   string
+	// Monitor is the analyzed monitor, used by the notification templates
+	Monitor ReportMonitor
 }
 
 func ContainsStringFold(s []string, e string) bool {
@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -37,6 +36,12 @@ func (s *State) String() string {
 		return "KO"
 	case Ignored:
 		return "IGNORED"
+	case Maintenance:
+		return "MAINTENANCE"
+	case Pending:
+		return "PENDING"
+	case Flapping:
+		return "FLAPPING"
 	}
 	return "UNKNOWN"
 }
@@ -47,22 +52,27 @@ func (s *State) Min(t State) State {
 	return *s
 }
 
+func (s State) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
 func (s *State) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		for _, state := range []State{KO, Warn, OK, WarnOk, Ignored, Maintenance, Pending, Unknown, Flapping} {
+			if state.String() == name {
+				*s = state
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid state name: %s", name)
+	}
+
 	var value int
 	if err := json.Unmarshal(data, &value); err != nil {
 		return err
 	}
-	switch value {
-	case 0:
-		*s = KO
-	case 1:
-		*s = OK
-	case 2:
-		*s = Warn
-	default:
-		return errors.New("invalid state value")
-	}
-
+	*s = KumaCurrent.State(value)
 	return nil
 }
 
@@ -72,24 +82,73 @@ const (
 	OK
 	WarnOk
 	Ignored
+	Maintenance
+	// Pending is a beat retried by kuma before being considered as down, analyzed as a warning
+	Pending
+	// Unknown is a beat whose status is not supported, it is not taken into account by the analysis
+	Unknown
+	// Flapping is a monitor alternating between up and down
+	Flapping
 )
 
+// KumaRelease defines how the statuses of the heartbeats are encoded by kuma
+type KumaRelease int
+
+const (
+	// KumaLegacy releases only provide the status page from its HTML page, and report warnings as 2
+	KumaLegacy KumaRelease = iota
+	// KumaCurrent releases report pending beats as 2, and maintenance as 3
+	KumaCurrent
+)
+
+// State converts the status of a heartbeat to a State, Unknown if it is not supported by the release
+func (r KumaRelease) State(code int) State {
+	switch code {
+	case 0:
+		return KO
+	case 1:
+		return OK
+	case 2:
+		if r == KumaLegacy {
+			return Warn
+		}
+		return Pending
+	case 3:
+		if r == KumaCurrent {
+			return Maintenance
+		}
+	}
+	return Unknown
+}
+
 type StatusTime time.Time
 
+// statusTimeLayouts are the date formats used by kuma, depending on the release and the database
+var statusTimeLayouts = []string{"2006-01-02 15:04:05.000", "2006-01-02 15:04:05", time.RFC3339}
+
 func (st *StatusTime) UnmarshalJSON(b []byte) error {
 	s := strings.Trim(string(b), "\"")
-	t, err := time.Parse("2006-01-02 15:04:05.000", s)
-	if err != nil {
-		return err
+	if s == "null" || s == "" {
+		*st = StatusTime{}
+		return nil
 	}
-	*st = StatusTime(t)
-	return nil
+	var err error
+	for _, layout := range statusTimeLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, s)
+		if err == nil {
+			*st = StatusTime(t)
+			return nil
+		}
+	}
+	return err
 }
 
+// MarshalJSON writes the date as RFC 3339, with its offset, the dates given by kuma being UTC
 func (st StatusTime) MarshalJSON() ([]byte, error) {
 	t := time.Time(st)
-	s := t.Format("2006-01-02 15:04:05.000")
-	return []byte(s), nil
+	s := t.Format("2006-01-02T15:04:05.000Z07:00")
+	return json.Marshal(s)
 }
 
 type Status struct {
@@ -97,11 +156,85 @@ type Status struct {
 	Date   StatusTime `json:"time"`
 	Msg    string     `json:"msg"`
 	Ping   float64    `json:"ping"`
+	// Code is the status sent by kuma, -1 when the status was given by name
+	Code int `json:"-"`
+}
+
+// UnmarshalJSON keeps the status code sent by kuma, so that it can be interpreted according to the release.
+// An unsupported status is decoded as Unknown instead of failing the decoding of the whole dashboard
+func (s *Status) UnmarshalJSON(data []byte) error {
+	type status Status
+	raw := struct {
+		*status
+		Status json.RawMessage `json:"status"`
+	}{status: (*status)(s)}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	s.Code = -1
+	if err := json.Unmarshal(raw.Status, &s.Code); err == nil {
+		s.Status = KumaCurrent.State(s.Code)
+		return nil
+	}
+	s.Code = -1
+	return json.Unmarshal(raw.Status, &s.Status)
 }
 
 type KumaHeartBeatList map[string][]Status
 
 type UptimeList map[string]float64
+
+// Get returns the uptime of the monitor, kuma provides it keyed by "<monitor id>_<period in hours>"
+func (ul UptimeList) Get(monitorId string) Uptime {
+	uptime := Uptime{}
+	if value, ok := ul[monitorId+"_24"]; ok {
+		uptime.Day = &value
+	}
+	if value, ok := ul[monitorId+"_720"]; ok {
+		uptime.Month = &value
+	}
+	return uptime
+}
+
+// Uptime holds the uptime ratios (between 0 and 1) of a monitor, nil when not provided by kuma
+type Uptime struct {
+	Day   *float64
+	Month *float64
+}
+
+// Compare orders the uptimes by their 24h value, the lowest first and the unknown last
+func (u Uptime) Compare(other Uptime) int {
+	switch {
+	case u.Day == nil && other.Day == nil:
+		return 0
+	case u.Day == nil:
+		return 1
+	case other.Day == nil:
+		return -1
+	case *u.Day < *other.Day:
+		return -1
+	case *u.Day > *other.Day:
+		return 1
+	}
+	return 0
+}
+
+func (u Uptime) String() string {
+	var parts []string
+	if u.Day != nil {
+		parts = append(parts, fmt.Sprintf("24h %s", FormatPercent(*u.Day)))
+	}
+	if u.Month != nil {
+		parts = append(parts, fmt.Sprintf("30d %s", FormatPercent(*u.Month)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// FormatPercent formats a ratio as a percentage, keeping the decimals relevant for an SLA
+func FormatPercent(ratio float64) string {
+	return fmt.Sprintf("%.2f%%", ratio*100)
+}
+
 type Dashboard struct {
 	Uptime    UptimeList        `json:"uptimeList"`
 	HeartBeat KumaHeartBeatList `json:"heartbeatList"`
@@ -161,7 +294,9 @@ type Monitor struct {
 	Id                string
 	Name              string
 	IsIgnored         bool
+	IsOnlyLast        bool
 	Status            []Status
+	Uptime            Uptime
 	localState        State
 	globalState       State
 	analyzeStatusSync sync.Once
@@ -182,28 +317,57 @@ func IsInList(name string, list []string, regexList []*regexp.Regexp) bool {
 	return false
 }
 
+// states returns the states of the beats used by the analysis: the pending beats are considered as warnings,
+// and the unknown ones are skipped
+func (m *Monitor) states() []State {
+	states := make([]State, 0, len(m.Status))
+	for _, status := range m.Status {
+		switch status.Status {
+		case Unknown:
+			continue
+		case Pending:
+			states = append(states, Warn)
+		default:
+			states = append(states, status.Status)
+		}
+	}
+	return states
+}
+
 func (m *Monitor) analyzeStatus(ignoreConf IgnoreConfig) (State, State) {
 	m.analyzeStatusSync.Do(func() {
 		ignored := IsInList(m.Name, ignoreConf.Ignore, ignoreConf.RegexList)
 		onlyLast := IsInList(m.Name, ignoreConf.Onlylast, ignoreConf.OnlyLastRegexList)
 
 		m.IsIgnored = ignored
+		m.IsOnlyLast = onlyLast
 		defer func() {
 			for i := range m.Status {
-				if m.IsIgnored && m.Status[i].Status != OK {
+				if m.IsIgnored && m.Status[i].Status != OK && m.Status[i].Status != Maintenance && m.Status[i].Status != Unknown {
 					m.Status[i].Status = Ignored
 				}
 			}
 		}()
+		states := m.states()
+		// The rules of the monitor adjust the states computed below
+		defer m.applyRules(ignoreConf.Rules.Get(m.Name), states, ignored)
 		// If the monitor is empty (no state has been reported to uptime-kuma).
 		// We consider it as OK and return
-		if len(m.Status) == 0 {
+		if len(states) == 0 {
 			m.localState = OK
 			m.globalState = OK
 			return
 		}
 
-		lastState := m.Status[len(m.Status)-1].Status
+		lastState := states[len(states)-1]
+		// A monitor under maintenance is expected to be down,
+		// so it does not impact the global state whatever its history
+		if lastState == Maintenance {
+			m.localState = Maintenance
+			m.globalState = OK
+			return
+		}
+
 		// Only the last status is relevant.
 		if onlyLast {
 			// If the last status is either KO or Warn and the monitor is ignored,
@@ -259,12 +423,12 @@ func (m *Monitor) analyzeStatus(ignoreConf IgnoreConfig) (State, State) {
 		// We start to iterate over the status list from the last element to the first element
 		// Here we know that the last status is always OK
 		isWarnOk := false
-		for i := len(m.Status) - 1; i >= 0; i-- {
-			if i == len(m.Status)-1 {
+		for i := len(states) - 1; i >= 0; i-- {
+			if i == len(states)-1 {
 				// explicitly skip the last element as here it is always OK
 				continue
 			}
-			if m.Status[i].Status == KO {
+			if states[i] == KO {
 				// If we find a KO or Warn status, we set the local state to warn
 				m.localState = Warn
 
@@ -284,7 +448,7 @@ func (m *Monitor) analyzeStatus(ignoreConf IgnoreConfig) (State, State) {
 				//
 				return
 			}
-			if m.Status[i].Status == Warn {
+			if states[i] == Warn {
 				isWarnOk = true
 			}
 		}
@@ -303,6 +467,38 @@ func (m *Monitor) analyzeStatus(ignoreConf IgnoreConfig) (State, State) {
 	return m.localState, m.globalState
 }
 
+// Analyze returns the local and global states of the monitor: a flapping monitor is reported as such,
+// and treated as configured for the global state, and a slow monitor is promoted to warn
+func (m *Monitor) Analyze(c Config) (State, State) {
+	localState, globalState := m.analyzeStatus(c.IgnoreConfig)
+	if localState == Maintenance {
+		return localState, globalState
+	}
+	// A monitor down right now stays KO, the flapping detection only softens the monitors that recovered
+	if localState != KO && m.IsFlapping(c.Flapping) {
+		if m.IsIgnored {
+			return Flapping, OK
+		}
+		return Flapping, c.Flapping.State()
+	}
+	if !m.IsSlow(c.Latency) {
+		return localState, globalState
+	}
+	if m.IsIgnored {
+		if localState == OK || localState == WarnOk {
+			localState = Ignored
+		}
+		return localState, globalState
+	}
+	if localState == OK || localState == WarnOk {
+		localState = Warn
+	}
+	if globalState == OK {
+		globalState = Warn
+	}
+	return localState, globalState
+}
+
 func (m *Monitor) CheckFinalStatus(state State, ignored bool, onlyLast bool) bool {
 	if m.Status[len(m.Status)-1].Status == state {
 		m.localState = Warn
@@ -324,7 +520,7 @@ func (m *Monitor) Beats(c Config) string {
 		return ""
 	}
 	sb := strings.Builder{}
-	for _, status := range m.Status {
+	for _, status := range m.displayedBeats(c) {
 		if c.BeatEmoji && c.Emoji {
 			sb.WriteString(c.Symbol.GetBeatEmoji(status.Status))
 		} else {
@@ -336,7 +532,7 @@ func (m *Monitor) Beats(c Config) string {
 
 func (m *Monitor) GetName(length int, c Config) string {
 	color := ""
-	status, _ := m.analyzeStatus(c.IgnoreConfig)
+	status, _ := m.Analyze(c)
 	switch status {
 	case OK:
 		color = c.Color.OkBeat
@@ -348,6 +544,10 @@ func (m *Monitor) GetName(length int, c Config) string {
 		color = c.Color.WarnBeat
 	case KO:
 		color = c.Color.KoBeat
+	case Maintenance:
+		color = c.Color.MaintenanceBeat
+	case Flapping:
+		color = c.Color.Flapping
 	}
 	if !c.Beat && !c.Emoji {
 		length = 0
@@ -357,7 +557,7 @@ func (m *Monitor) GetName(length int, c Config) string {
 
 func (m *Monitor) EmojiBeats(c Config) string {
 	sb := strings.Builder{}
-	for _, status := range m.Status {
+	for _, status := range m.displayedBeats(c) {
 		sb.WriteString(c.Symbol.GetBeatEmoji(status.Status))
 	}
 	return sb.String()
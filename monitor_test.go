@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestStatusTimeJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`"2024-05-02 14:03:00.250"`, `"2024-05-02T14:03:00.250Z"`},
+		{`"2024-05-02 14:03:00"`, `"2024-05-02T14:03:00.000Z"`},
+		{`"2024-05-02T16:03:00.250+02:00"`, `"2024-05-02T16:03:00.250+02:00"`},
+	}
+	for _, test := range tests {
+		var st StatusTime
+		if err := json.Unmarshal([]byte(test.input), &st); err != nil {
+			t.Fatalf("unable to decode %s: %s", test.input, err)
+		}
+		got, err := json.Marshal(st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s encoded as %s, want %s", test.input, got, test.want)
+		}
+		var decoded StatusTime
+		if err := json.Unmarshal(got, &decoded); err != nil || !time.Time(decoded).Equal(time.Time(st)) {
+			t.Errorf("%s decoded as %v, want %v (%v)", got, time.Time(decoded), time.Time(st), err)
+		}
+	}
+}
@@ -3,11 +3,14 @@ package main
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/containrrr/shoutrrr"
 	"github.com/containrrr/shoutrrr/pkg/router"
+	"github.com/containrrr/shoutrrr/pkg/services/slack"
 	"github.com/containrrr/shoutrrr/pkg/types"
 )
 
@@ -18,10 +21,13 @@ const (
 	blue   = "0x00D7FF"
 )
 
-// Notifier holds the shoutrrr service used to send discord notification
+// Notifier holds the services used to send the notifications, the messages being rendered according to each service
 type Notifier struct {
 	Urls    []string
-	senders []*router.ServiceRouter
+	targets []notifyTarget
+	client  *http.Client
+	retries int
+	backoff time.Duration
 	debug   bool
 }
 
@@ -35,13 +41,48 @@ func NewColoredStringBuilder() ColoredStringBuilder {
 type ColoredStringBuilder struct {
 	*strings.Builder
 	State State
+	// Override is used instead of the color of the state when set
+	Override string
+	// states are the states of the monitors of the message
+	states []State
 }
 
+// colorState returns the state whose color is used for s, a flapping monitor being colored as a warning
+func colorState(s State) State {
+	if s == Flapping {
+		return Warn
+	}
+	return s
+}
+
+// colorOf returns the state whose color is used for a set of monitors: the worst state of the monitors neither
+// ignored nor under maintenance, Ignored when every monitor is, so that they are not colored as OK
+func colorOf(states []State) State {
+	color := OK
+	colored := false
+	for _, s := range states {
+		if s == Ignored || s == Maintenance {
+			continue
+		}
+		color = color.Min(colorState(s))
+		colored = true
+	}
+	if !colored && len(states) > 0 {
+		return Ignored
+	}
+	return color
+}
+
+// Colorize adds the state of a monitor to the ones coloring the message
 func (csb *ColoredStringBuilder) Colorize(s State) {
-	csb.State = csb.State.Min(s)
+	csb.states = append(csb.states, s)
+	csb.State = colorOf(csb.states)
 }
 
 func (csb *ColoredStringBuilder) Color() string {
+	if csb.Override != "" {
+		return csb.Override
+	}
 	switch csb.State {
 	case Warn:
 		return yellow
@@ -51,71 +92,238 @@ func (csb *ColoredStringBuilder) Color() string {
 		return green
 	case KO:
 		return red
-	case Ignored:
+	case Ignored, Maintenance:
 		return blue
 	}
 	return ""
 }
 
-// NewNotifier returns the Notifier struct used to send discord notification
-// it returns an error if the discord notification URL cannot be parsed by the underlying shoutrrr
-func NewNotifier(c Config) (error, *Notifier) {
-	var senders []*router.ServiceRouter
+// notifyTarget is a notification URL, with the service used to render and send its messages
+type notifyTarget struct {
+	url     string
+	service string
+	sender  *router.ServiceRouter
+	slack   *slack.Config
+	limits  MessageLimits
+	// err is the reason why the URL cannot be notified
+	err error
+}
+
+// NewNotifier returns the Notifier struct used to send the notifications
+// it returns an error if a notification URL cannot be parsed, the URLs that cannot be parsed being reported
+// as failed by the returned Notifier
+func NewNotifier(c Config) (*Notifier, error) {
+	if len(c.NotifyUrl) == 0 {
+		return nil, fmt.Errorf("no notify url")
+	}
+	// The services of shoutrrr send their requests with the default client
+	http.DefaultClient.Transport = c.HTTP.GetTransport()
+	var targets []notifyTarget
 	var errs []error
-	for _, url := range c.NotifyUrl {
-		sender, err := shoutrrr.CreateSender(url)
+	for _, notifyUrl := range c.NotifyUrl {
+		target := notifyTarget{url: notifyUrl}
+		u, err := url.Parse(notifyUrl)
+		if err == nil {
+			target.service = strings.ToLower(u.Scheme)
+			target.limits = ServiceLimits(target.service, u)
+			if target.service == slack.Scheme {
+				// The Block Kit messages are sent directly, shoutrrr only handles plain text
+				target.slack, err = slack.CreateConfigFromURL(u)
+			} else {
+				target.sender, err = shoutrrr.CreateSender(notifyUrl)
+			}
+		}
 		if err != nil {
-			errs = append(errs, fmt.Errorf("error while parsing discord url (%s): %s", c.NotifyUrl, err))
-			continue
+			target.err = fmt.Errorf("error while parsing notification url: %s", err)
+			errs = append(errs, target.err)
 		}
-		senders = append(senders, sender)
-	}
-	if len(senders) == 0 {
-		return fmt.Errorf("error, no valid webhook found"), nil
+		targets = append(targets, target)
 	}
 
-	return errors.Join(errs...), &Notifier{
+	return &Notifier{
 		Urls:    c.NotifyUrl,
-		senders: senders,
-	}
+		targets: targets,
+		client:  &http.Client{Timeout: 10 * time.Second, Transport: c.HTTP.GetTransport()},
+		retries: c.NotifyRetries,
+		backoff: c.NotifyBackoff,
+	}, errors.Join(errs...)
+}
 
+// incidentMessage returns the message of the incident, colored according to its style
+func incidentMessage(incident *Incident, config Config, markdown bool, limits MessageLimits) ColoredStringBuilder {
+	message := NewColoredStringBuilder()
+	message.Override = incident.NotificationColor()
+	for i, line := range incident.Lines() {
+		line = fmt.Sprintf("%s\n", line)
+		if i == 0 {
+			line = fmt.Sprintf("%s %s", config.Symbol.Incident, line)
+			if markdown {
+				line = "### " + line
+			}
+		}
+		if !limits.fits(message.String(), line, "") {
+			break
+		}
+		message.WriteString(line)
+	}
+	return message
 }
 
-func (n *Notifier) Notify(content Content, config Config) {
-	webhookLimit := 1990
+// monitorEntry returns the lines of the monitor, with its ANSI colors when they are rendered
+func monitorEntry(monitor ParsedMonitor, ansi bool) string {
+	sb := strings.Builder{}
+	name := monitor.Name
+	if !ansi {
+		name = strings.TrimSpace(removeANSICodes(name))
+	}
+	if monitor.Uptime != "" {
+		sb.WriteString(fmt.Sprintf("%s %s (%s)\n", monitor.Emoji, name, monitor.Uptime))
+	} else {
+		sb.WriteString(fmt.Sprintf("%s %s\n", monitor.Emoji, name))
+	}
+	if monitor.Failure != "" {
+		sb.WriteString(fmt.Sprintf("↳ %s\n", monitor.Failure))
+	}
+	if monitor.Downtime != "" {
+		sb.WriteString(fmt.Sprintf("↳ %s\n", monitor.Downtime))
+	}
+	if monitor.Latency != "" {
+		sb.WriteString(fmt.Sprintf("%s\n", monitor.Latency))
+	}
+	if monitor.Details != "" {
+		sb.WriteString(fmt.Sprintf("%s\n", monitor.Details))
+	}
+	if ansi {
+		sb.WriteString(monitor.EmojiBeats)
+	} else if beats := strings.TrimSpace(removeANSICodes(monitor.EmojiBeats)); beats != "" {
+		sb.WriteString(beats + "\n")
+	}
+	return sb.String()
+}
 
-	var messages []ColoredStringBuilder
-	// message.WriteString("# Down status\n")
+// groupParts returns the parts of the groups: the name of a group is kept with its first monitor,
+// and in markdown the monitors of a group are written in an ANSI code block
+func groupParts(content Content, markdown bool) []messagePart {
+	var parts []messagePart
 	for _, group := range content.Content {
-		message := NewColoredStringBuilder()
-		message.WriteString(fmt.Sprintf("\n### %s\n```ansi\n", removeANSICodes(group.GroupName)))
-		for _, monitor := range group.Monitors {
-			message.Colorize(monitor.State)
-			if message.Len() > webhookLimit {
-				message.WriteString("\n```")
-				messages = append(messages, message)
-				message = NewColoredStringBuilder()
-				message.WriteString("```ansi\n")
+		name := removeANSICodes(group.GroupName)
+		header := fmt.Sprintf("%s\n", name)
+		if markdown {
+			header = fmt.Sprintf("\n### %s\n```ansi\n", name)
+		}
+		for i, monitor := range group.Monitors {
+			part := messagePart{
+				Text:  monitorEntry(monitor, markdown),
+				State: monitor.State,
+			}
+			if i == 0 {
+				part.Text = header + part.Text
+			}
+			if markdown && i == len(group.Monitors)-1 {
+				part.Text += fenceClose
 			}
-			message.WriteString(fmt.Sprintf("%s %s\n%s", monitor.Emoji, monitor.Name, monitor.EmojiBeats))
+			parts = append(parts, part)
 		}
-		message.WriteString("```\n")
-		messages = append(messages, message)
 	}
+	return parts
+}
+
+// RenderDiscord renders the content as discord markdown, the monitors being written in ANSI code blocks.
+// The incident is sent in its own message, and the groups are packed into as few messages as the limits allow
+func RenderDiscord(content Content, config Config, limits MessageLimits) []ColoredStringBuilder {
+	var messages []ColoredStringBuilder
+	if content.Incident != nil {
+		messages = append(messages, incidentMessage(content.Incident, config, true, limits))
+	}
+	return append(messages, PackMessages(groupParts(content, true), limits)...)
+}
+
+// RenderPlain renders the content as plain text, for the services supporting neither markdown nor ANSI colors
+func RenderPlain(content Content, config Config, limits MessageLimits) []ColoredStringBuilder {
+	var messages []ColoredStringBuilder
+	if content.Incident != nil {
+		messages = append(messages, incidentMessage(content.Incident, config, false, limits))
+	}
+	return append(messages, PackMessages(groupParts(content, false), limits)...)
+}
 
+// Notify sends the content to every notification URL, and returns the result of the delivery to each of them
+func (n *Notifier) Notify(content Content, config Config) Deliveries {
+	var deliveries Deliveries
+	for _, target := range n.targets {
+		delivery := Delivery{Url: target.url}
+		if target.err != nil {
+			delivery.Errors = []error{target.err}
+		} else {
+			delivery.Sent, delivery.Errors = n.send(target, content, config)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries
+}
+
+// send renders the content for the service of the target, and sends the resulting messages.
+// It returns the number of messages sent
+func (n *Notifier) send(target notifyTarget, content Content, config Config) (int, []error) {
+	if tmpl := config.Templates.Get(target.url, target.service); tmpl != nil {
+		text, err := RenderTemplate(tmpl, content, config)
+		if err != nil {
+			return 0, []error{err}
+		}
+		// Nothing is sent when the template renders blank
+		if strings.TrimSpace(text) == "" {
+			return 0, nil
+		}
+		if target.slack != nil {
+			return n.sendSlack(target, []SlackMessage{{Text: text}})
+		}
+		var parts []messagePart
+		for _, line := range strings.SplitAfter(text, "\n") {
+			parts = append(parts, messagePart{Text: line, State: content.State})
+		}
+		return n.sendMessages(target, PackMessages(parts, target.limits))
+	}
+
+	if target.slack != nil {
+		return n.sendSlack(target, PackSlack(RenderSlack(content, config), target.limits))
+	}
+	if target.service == "discord" {
+		return n.sendMessages(target, RenderDiscord(content, config, target.limits))
+	}
+	return n.sendMessages(target, RenderPlain(content, config, target.limits))
+}
+
+func (n *Notifier) sendSlack(target notifyTarget, messages []SlackMessage) (int, []error) {
+	sent := 0
+	var errs []error
 	for _, message := range messages {
-		msg := message.String()
-		for _, sender := range n.senders {
-			errs := sender.Send(msg, &types.Params{"Color": message.Color()})
-			if len(errs) > 0 {
-				for _, err := range errs {
-					if err != nil {
-						fmt.Println(err)
-					}
-				}
-			}
+		err := retry(n.retries, n.backoff, func() error {
+			return SendSlack(n.client, target.slack, message)
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
 		}
-		time.Sleep(500 * time.Millisecond)
+		sent++
 	}
+	return sent, errs
+}
 
+func (n *Notifier) sendMessages(target notifyTarget, messages []ColoredStringBuilder) (int, []error) {
+	sent := 0
+	var errs []error
+	for i, message := range messages {
+		if i > 0 {
+			time.Sleep(500 * time.Millisecond)
+		}
+		err := retry(n.retries, n.backoff, func() error {
+			return errors.Join(target.sender.Send(message.String(), &types.Params{"Color": message.Color()})...)
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sent++
+	}
+	return sent, errs
 }
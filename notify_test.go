@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestGroupColor(t *testing.T) {
+	tests := []struct {
+		name   string
+		states []State
+		want   string
+	}{
+		{"ok", []State{OK, WarnOk}, green},
+		{"ignored first", []State{Ignored, OK}, green},
+		{"ignored last", []State{OK, Ignored}, green},
+		{"ignored before a failure", []State{Ignored, Maintenance, KO}, red},
+		{"warn and ko", []State{Warn, KO, OK}, red},
+		{"flapping", []State{OK, Flapping}, yellow},
+		{"only ignored", []State{Ignored, Ignored}, blue},
+		{"ignored and maintenance", []State{Maintenance, Ignored}, blue},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			group := ParsedGroups{GroupName: "Web"}
+			for _, state := range test.states {
+				group.Monitors = append(group.Monitors, ParsedMonitor{State: state, Name: "monitor"})
+			}
+			content := Content{Header: "prod", Content: []ParsedGroups{group}}
+
+			slack := RenderSlack(content, Config{})
+			if color := slack.Attachments[0].Color; color != slackColor(test.want) {
+				t.Errorf("slack color = %s, want %s", color, slackColor(test.want))
+			}
+			discord := RenderDiscord(content, Config{}, MessageLimits{Chars: 2000})
+			if color := discord[0].Color(); color != test.want {
+				t.Errorf("discord color = %s, want %s", color, test.want)
+			}
+		})
+	}
+}
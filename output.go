@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rivo/uniseg"
+)
+
+const (
+	SortName   = "name"
+	SortUptime = "uptime"
+)
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// RenderText builds the colored text synthesis of the report, used by the terminal, xbar and the notifications
+func RenderText(config Config, report Report) Content {
+	length := 0
+	maxWidth := 0
+	for _, group := range report.Groups {
+		for _, monitor := range group.Monitors {
+			l := len(monitor.Name)
+			if l > length {
+				length = l
+			}
+
+			l = uniseg.GraphemeClusterCount(removeANSICodes(monitor.Beats(config)))
+			if l > maxWidth {
+				maxWidth = l
+			}
+		}
+	}
+
+	content := Content{}
+	now := time.Now()
+
+	for _, group := range report.Groups {
+		contentGroup := ParsedGroups{
+			GroupName: group.Group.Name,
+		}
+
+		for _, monitor := range group.Monitors {
+			nb := countChar(monitor.Beats(config), config)
+
+			pad := maxWidth - nb
+			if pad < 0 {
+				pad = 0
+			}
+			if config.BeatEmoji && config.Emoji {
+				pad *= 2
+			}
+
+			beats := fmt.Sprintf("%-*s%s ", pad, "", monitor.Beats(config))
+			if config.Uptime && monitor.Uptime.Day != nil {
+				beats = fmt.Sprintf("%s%s ", beats, monitor.Uptime)
+			}
+			latency := ""
+			if stats := monitor.Latency(); stats.Count > 0 && (config.Latency.Show || monitor.Slow) {
+				latency = stats.String()
+				if config.Latency.Show {
+					beats = fmt.Sprintf("%s%s %s ", beats, monitor.Sparkline(config), latency)
+				} else {
+					beats = fmt.Sprintf("%s%s ", beats, latency)
+				}
+			}
+
+			if config.Xbar {
+				beats = fmt.Sprintf("%s | font=\"FiraCode Nerd Font\"\n", beats)
+			} else {
+				beats = fmt.Sprintf("%s\n", beats)
+			}
+
+			failure := ""
+			downtime := ""
+			if monitor.LocalState == KO || monitor.LocalState == Warn || monitor.LocalState == Flapping {
+				if lastFailure := monitor.LastFailure(); lastFailure != nil {
+					failure = lastFailure.String()
+				}
+				monitorDowntime := monitor.Downtime(now)
+				monitorDowntime.Window = config.Window
+				downtime = monitorDowntime.String()
+			}
+			for _, line := range []string{failure, downtime} {
+				if line == "" {
+					continue
+				}
+				if config.Xbar {
+					// Displayed as a submenu of the monitor
+					beats = fmt.Sprintf("%s--%s\n", beats, strings.ReplaceAll(line, "|", "¦"))
+				} else {
+					beats = fmt.Sprintf("%s  ↳ %s\n", beats, line)
+				}
+			}
+
+			parsedMonitor := ParsedMonitor{
+				State:      monitor.LocalState,
+				Emoji:      config.Symbol.Get(monitor.LocalState),
+				Beats:      beats,
+				EmojiBeats: fmt.Sprintf("%-*s%s \n", pad, "", monitor.EmojiBeats(config)),
+				Name:       monitor.GetName(length, config),
+				Uptime:     monitor.Uptime.String(),
+				Latency:    latency,
+				Failure:    failure,
+				Downtime:   downtime,
+				Monitor:    monitor,
+			}
+			if monitor.Change != nil {
+				parsedMonitor.Details = monitor.Change.String(monitor.GlobalState)
+			}
+			contentGroup.Monitors = append(contentGroup.Monitors, parsedMonitor)
+		}
+		if contentGroup.IsOK() {
+			contentGroup.GroupName = fmt.Sprintf("\u001B[%dm%s\u001B[0m", colors[config.Color.OkBeat], contentGroup.GroupName)
+		}
+
+		if contentGroup.IsWarn() {
+			contentGroup.GroupName = fmt.Sprintf("\u001B[%dm%s\u001B[0m", colors[config.Color.WarnBeat], contentGroup.GroupName)
+		}
+
+		if contentGroup.IsKO() {
+			contentGroup.GroupName = fmt.Sprintf("\u001B[%dm%s\u001B[0m", colors[config.Color.KoBeat], contentGroup.GroupName)
+		}
+		content.Content = append(content.Content, contentGroup)
+	}
+
+	content.Header = report.Dashboard
+	content.State = report.GlobalState
+	content.Incident = report.Incident
+	content.Banner = RenderIncident(config, report.Incident)
+	for _, warning := range report.Warnings {
+		content.Warnings += fmt.Sprintf("\u001B[%dm%s %s\u001B[0m\n", colors[config.Color.WarnBeat], config.Symbol.Warn, warning)
+	}
+	if config.Xbar && content.Warnings != "" {
+		content.Warnings = strings.ReplaceAll(content.Warnings, "|", "¦") + "---\n"
+	}
+	if config.Xbar {
+		icon := config.Symbol.Get(report.GlobalState)
+
+		content.Header = fmt.Sprintf("%s %s\n---", report.Dashboard, icon)
+		content.Footer = "Refresh... | refresh=true"
+	}
+
+	return content
+}
+
+// RenderError builds the section displayed in place of a dashboard that could not be fetched
+func RenderError(config Config, dash string, err error) Content {
+	content := Content{
+		Header: dash,
+		Content: []ParsedGroups{{
+			GroupName: fmt.Sprintf("\u001B[%dm%s %s\u001B[0m", colors[config.Color.KoBeat], config.Symbol.Error, err),
+		}},
+	}
+	if config.Xbar {
+		content.Header = fmt.Sprintf("%s %s\n---", dash, config.Symbol.Error)
+		content.Footer = "Refresh... | refresh=true"
+	}
+	return content
+}
+
+type JSONReport struct {
+	Dashboard   string      `json:"dashboard"`
+	GlobalState State       `json:"globalState"`
+	Groups      []JSONGroup `json:"groups"`
+	Incident    *Incident   `json:"incident,omitempty"`
+	Warnings    []string    `json:"warnings,omitempty"`
+	Error       string      `json:"error,omitempty"`
+}
+
+type JSONGroup struct {
+	Id       int           `json:"id"`
+	Name     string        `json:"name"`
+	Monitors []JSONMonitor `json:"monitors"`
+}
+
+type JSONMonitor struct {
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	LocalState  State    `json:"localState"`
+	GlobalState State    `json:"globalState"`
+	Ignored     bool     `json:"ignored"`
+	OnlyLast    bool     `json:"onlyLast"`
+	Beats       []Status `json:"beats"`
+	// Uptime24h and Uptime30d are ratios between 0 and 1, as provided by kuma
+	Uptime24h *float64 `json:"uptime24h,omitempty"`
+	Uptime30d *float64 `json:"uptime30d,omitempty"`
+	// Latency is omitted when no ping is available
+	Latency *LatencyStats `json:"latency,omitempty"`
+	Slow    bool          `json:"slow"`
+	// LastFailure is the last failure message given by kuma, whatever the state of the monitor
+	LastFailure      string     `json:"lastFailure,omitempty"`
+	LastFailureSince *time.Time `json:"lastFailureSince,omitempty"`
+	// StateSince is the date of the first beat of the current state
+	StateSince      *time.Time `json:"stateSince,omitempty"`
+	DowntimeSeconds float64    `json:"downtimeSeconds"`
+	Outages         int        `json:"outages"`
+	// PreviousState is the state before the change, and PreviousStateSeconds how long it lasted,
+	// when the monitor is reported because its state changed
+	PreviousState        *State  `json:"previousState,omitempty"`
+	PreviousStateSeconds float64 `json:"previousStateSeconds,omitempty"`
+}
+
+// NewJSONReport converts the report to its machine-readable representation
+func NewJSONReport(report Report) JSONReport {
+	jsonReport := JSONReport{
+		Dashboard:   report.Dashboard,
+		GlobalState: report.GlobalState,
+		Groups:      []JSONGroup{},
+		Incident:    report.Incident,
+		Warnings:    report.Warnings,
+	}
+	for _, group := range report.Groups {
+		jsonGroup := JSONGroup{
+			Id:       group.Group.Id,
+			Name:     group.Group.Name,
+			Monitors: []JSONMonitor{},
+		}
+		for _, monitor := range group.Monitors {
+			beats := monitor.Status
+			if beats == nil {
+				beats = []Status{}
+			}
+			var latency *LatencyStats
+			if stats := monitor.Latency(); stats.Count > 0 {
+				latency = &stats
+			}
+			jsonMonitor := JSONMonitor{
+				Id:          monitor.Id,
+				Name:        monitor.Name,
+				LocalState:  monitor.LocalState,
+				GlobalState: monitor.GlobalState,
+				Ignored:     monitor.IsIgnored,
+				OnlyLast:    monitor.IsOnlyLast,
+				Beats:       beats,
+				Uptime24h:   monitor.Uptime.Day,
+				Uptime30d:   monitor.Uptime.Month,
+				Latency:     latency,
+				Slow:        monitor.Slow,
+			}
+			if downtime := monitor.Downtime(time.Now()); downtime.Beats > 0 {
+				jsonMonitor.StateSince = &downtime.Since
+				jsonMonitor.DowntimeSeconds = downtime.Duration.Seconds()
+				jsonMonitor.Outages = downtime.Outages
+			}
+			if failure := monitor.LastFailure(); failure != nil {
+				jsonMonitor.LastFailure = failure.Msg
+				jsonMonitor.LastFailureSince = &failure.Since
+			}
+			if monitor.Change != nil {
+				jsonMonitor.PreviousState = &monitor.Change.Previous
+				jsonMonitor.PreviousStateSeconds = monitor.Change.Duration.Seconds()
+			}
+			jsonGroup.Monitors = append(jsonGroup.Monitors, jsonMonitor)
+		}
+		jsonReport.Groups = append(jsonReport.Groups, jsonGroup)
+	}
+	return jsonReport
+}
+
+// RenderJSON writes the report as an indented JSON document, alongside the error that prevented to fetch it if any
+func RenderJSON(w io.Writer, report Report, err error) error {
+	jsonReport := NewJSONReport(report)
+	if err != nil {
+		jsonReport.Error = err.Error()
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(jsonReport)
+}
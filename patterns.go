@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PatternValues associates values to monitor names, or to regexes when prefixed with "re:"
+type PatternValues[T any] []patternValue[T]
+
+type patternValue[T any] struct {
+	name  string
+	regex *regexp.Regexp
+	value T
+}
+
+// CompilePatterns parses the patterns of the map, they are checked in their alphabetical order
+func CompilePatterns[T any](values map[string]T) (PatternValues[T], error) {
+	var patterns []string
+	for pattern := range values {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	var compiled PatternValues[T]
+	for _, pattern := range patterns {
+		value := patternValue[T]{
+			value: values[pattern],
+		}
+		if strings.HasPrefix(pattern, "re:") {
+			regex, err := regexp.Compile(strings.TrimPrefix(pattern, "re:"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern (%s): %s", pattern, err)
+			}
+			value.regex = regex
+		} else {
+			value.name = pattern
+		}
+		compiled = append(compiled, value)
+	}
+	return compiled, nil
+}
+
+// Get returns the value of the first pattern matching the name
+func (p PatternValues[T]) Get(name string) (T, bool) {
+	for _, pattern := range p {
+		if pattern.name == name || (pattern.regex != nil && pattern.regex.MatchString(name)) {
+			return pattern.value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
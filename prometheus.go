@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const OutputPrometheus = "prometheus"
+
+// PrometheusMetric is a metric family of the Prometheus text exposition format
+type PrometheusMetric struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []PrometheusSample
+}
+
+type PrometheusSample struct {
+	Labels [][2]string
+	Value  float64
+}
+
+func (m *PrometheusMetric) Add(value float64, labels ...[2]string) {
+	m.Samples = append(m.Samples, PrometheusSample{
+		Labels: labels,
+		Value:  value,
+	})
+}
+
+func (m *PrometheusMetric) WriteTo(w io.Writer) (int64, error) {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("# HELP %s %s\n", m.Name, m.Help))
+	sb.WriteString(fmt.Sprintf("# TYPE %s %s\n", m.Name, m.Type))
+	for _, sample := range m.Samples {
+		sb.WriteString(m.Name)
+		if len(sample.Labels) > 0 {
+			var labels []string
+			for _, label := range sample.Labels {
+				labels = append(labels, fmt.Sprintf("%s=\"%s\"", label[0], escapeLabelValue(label[1])))
+			}
+			sb.WriteString(fmt.Sprintf("{%s}", strings.Join(labels, ",")))
+		}
+		sb.WriteString(fmt.Sprintf(" %g\n", sample.Value))
+	}
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func label(name string, value string) [2]string {
+	return [2]string{name, value}
+}
+
+// WritePrometheus writes the metrics of the reports, the fetch errors are counted per dashboard
+func WritePrometheus(w io.Writer, reports []JSONReport, fetchErrors map[string]int) error {
+	stateHelp := fmt.Sprintf("(%d: KO, %d: WARN, %d: OK, %d: WARN_OK, %d: IGNORED, %d: MAINTENANCE, %d: PENDING, %d: UNKNOWN, %d: FLAPPING)", KO, Warn, OK, WarnOk, Ignored, Maintenance, Pending, Unknown, Flapping)
+	localState := &PrometheusMetric{
+		Name: "kumago_monitor_local_state",
+		Help: "Local state of the monitor " + stateHelp,
+		Type: "gauge",
+	}
+	globalState := &PrometheusMetric{
+		Name: "kumago_monitor_global_state",
+		Help: "Global state of the monitor " + stateHelp,
+		Type: "gauge",
+	}
+	ignored := &PrometheusMetric{
+		Name: "kumago_monitor_ignored",
+		Help: "Whether the monitor is in the ignore list",
+		Type: "gauge",
+	}
+	lastPing := &PrometheusMetric{
+		Name: "kumago_monitor_last_ping_ms",
+		Help: "Response time of the last heartbeat of the monitor, in milliseconds",
+		Type: "gauge",
+	}
+	uptime := &PrometheusMetric{
+		Name: "kumago_monitor_uptime_ratio",
+		Help: "Uptime of the monitor computed by kuma over the period (24h, 30d)",
+		Type: "gauge",
+	}
+	latency := &PrometheusMetric{
+		Name: "kumago_monitor_latency_ms",
+		Help: "Statistics of the response times of the monitor (min, avg, p95, max), in milliseconds",
+		Type: "gauge",
+	}
+	outages := &PrometheusMetric{
+		Name: "kumago_monitor_outages",
+		Help: "Number of outages of the monitor in the beats provided by kuma",
+		Type: "gauge",
+	}
+	downtime := &PrometheusMetric{
+		Name: "kumago_monitor_downtime_seconds",
+		Help: "Time spent down by the monitor in the beats provided by kuma",
+		Type: "gauge",
+	}
+	dashboardState := &PrometheusMetric{
+		Name: "kumago_dashboard_global_state",
+		Help: "Global state of the dashboard " + stateHelp,
+		Type: "gauge",
+	}
+	incident := &PrometheusMetric{
+		Name: "kumago_dashboard_incident",
+		Help: "Whether an incident is posted on the status page",
+		Type: "gauge",
+	}
+	errorsTotal := &PrometheusMetric{
+		Name: "kumago_dashboard_fetch_errors_total",
+		Help: "Number of failed fetches of the dashboard",
+		Type: "counter",
+	}
+
+	for _, report := range reports {
+		dashboard := label("dashboard", report.Dashboard)
+		for _, group := range report.Groups {
+			for _, monitor := range group.Monitors {
+				labels := [][2]string{dashboard, label("group", group.Name), label("monitor", monitor.Name)}
+				localState.Add(float64(monitor.LocalState), labels...)
+				globalState.Add(float64(monitor.GlobalState), labels...)
+				isIgnored := 0.0
+				if monitor.Ignored {
+					isIgnored = 1
+				}
+				ignored.Add(isIgnored, labels...)
+				if monitor.Uptime24h != nil {
+					uptime.Add(*monitor.Uptime24h, append(labels, label("period", "24h"))...)
+				}
+				if monitor.Uptime30d != nil {
+					uptime.Add(*monitor.Uptime30d, append(labels, label("period", "30d"))...)
+				}
+				outages.Add(float64(monitor.Outages), labels...)
+				downtime.Add(monitor.DowntimeSeconds, labels...)
+				if monitor.Latency != nil {
+					latency.Add(monitor.Latency.Min, append(labels, label("stat", "min"))...)
+					latency.Add(monitor.Latency.Avg, append(labels, label("stat", "avg"))...)
+					latency.Add(monitor.Latency.P95, append(labels, label("stat", "p95"))...)
+					latency.Add(monitor.Latency.Max, append(labels, label("stat", "max"))...)
+				}
+				if len(monitor.Beats) > 0 {
+					lastPing.Add(monitor.Beats[len(monitor.Beats)-1].Ping, labels...)
+				}
+			}
+		}
+		if report.Error == "" || len(report.Groups) > 0 {
+			dashboardState.Add(float64(report.GlobalState), dashboard)
+			if report.Incident != nil {
+				incident.Add(1, dashboard, label("style", report.Incident.Style))
+			} else {
+				incident.Add(0, dashboard, label("style", ""))
+			}
+		}
+		errorsTotal.Add(float64(fetchErrors[report.Dashboard]), dashboard)
+	}
+
+	for _, metric := range []*PrometheusMetric{localState, globalState, ignored, lastPing, uptime, latency, outages, downtime, dashboardState, incident, errorsTotal} {
+		_, err := metric.WriteTo(w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
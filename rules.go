@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RulesConfig holds the rules adjusting the state analysis of the monitors, per monitor name or regex
+type RulesConfig struct {
+	Consecutive   map[string]int     `help:"Number of consecutive KO beats required for a monitor to be KO, per monitor (prefix with \"re:\" to match using regexes)"`
+	ErrorRatio    map[string]float64 `help:"Percentage of KO beats above which a monitor is warn, per monitor (prefix with \"re:\" to match using regexes)"`
+	RecoveryGrace map[string]int     `help:"Number of OK beats required after an outage for a monitor to be recovered, per monitor (prefix with \"re:\" to match using regexes)"`
+	consecutive   PatternValues[int]
+	errorRatio    PatternValues[float64]
+	recoveryGrace PatternValues[int]
+}
+
+// Rule is the set of rules applying to a monitor
+type Rule struct {
+	Consecutive int
+	// ErrorRatio is a percentage, nil when the rule is not set
+	ErrorRatio    *float64
+	RecoveryGrace int
+}
+
+// Compile parses the patterns of the rules
+func (r *RulesConfig) Compile() error {
+	var errs []error
+	var err error
+	r.consecutive, err = CompilePatterns(r.Consecutive)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid consecutive rule: %s", err))
+	}
+	r.errorRatio, err = CompilePatterns(r.ErrorRatio)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid error ratio rule: %s", err))
+	}
+	r.recoveryGrace, err = CompilePatterns(r.RecoveryGrace)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid recovery grace rule: %s", err))
+	}
+	return errors.Join(errs...)
+}
+
+// Get returns the rules applying to the monitor
+func (r *RulesConfig) Get(name string) Rule {
+	rule := Rule{}
+	rule.Consecutive, _ = r.consecutive.Get(name)
+	if ratio, ok := r.errorRatio.Get(name); ok {
+		rule.ErrorRatio = &ratio
+	}
+	rule.RecoveryGrace, _ = r.recoveryGrace.Get(name)
+	return rule
+}
+
+// applyRules adjusts the states computed by the analysis according to the rules of the monitor:
+//   - a KO monitor is only warn until it has been KO for the required consecutive beats
+//   - a recovered monitor stays KO until it has been OK for the grace beats
+//   - a monitor whose error ratio exceeds the limit is warn, even when only its last beat is analyzed
+func (m *Monitor) applyRules(rule Rule, states []State, ignored bool) {
+	if ignored || len(states) == 0 || m.localState == Maintenance {
+		return
+	}
+	var beats []State
+	for _, state := range states {
+		if state != Maintenance {
+			beats = append(beats, state)
+		}
+	}
+	if len(beats) == 0 {
+		return
+	}
+
+	// Last run of KO beats, and the number of beats since
+	end := len(beats)
+	for end > 0 && beats[end-1] != KO {
+		end--
+	}
+	start := end
+	for start > 0 && beats[start-1] == KO {
+		start--
+	}
+	outage := end - start
+	since := len(beats) - end
+	confirmed := outage > 0 && outage >= rule.Consecutive
+
+	if since == 0 {
+		if !confirmed {
+			m.localState = Warn
+			m.globalState = Warn
+		}
+		return
+	}
+	if confirmed && since < rule.RecoveryGrace {
+		m.localState = KO
+		m.globalState = KO
+		return
+	}
+
+	if rule.ErrorRatio == nil || beats[len(beats)-1] != OK {
+		return
+	}
+	ko := 0
+	for _, state := range beats {
+		if state == KO {
+			ko++
+		}
+	}
+	if float64(ko)/float64(len(beats))*100 > *rule.ErrorRatio {
+		m.localState = Warn
+		m.globalState = Warn
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestApplyRules(t *testing.T) {
+	rules := RulesConfig{
+		Consecutive:   map[string]int{"re:^api": 3},
+		ErrorRatio:    map[string]float64{"re:^api": 25, "batch": 50},
+		RecoveryGrace: map[string]int{"database": 2},
+	}
+	if err := rules.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		monitor    string
+		states     []State
+		onlyLast   bool
+		wantLocal  State
+		wantGlobal State
+	}{
+		{"no rule", "web", []State{OK, OK, KO}, false, KO, KO},
+		{"outage not confirmed yet", "api-v1", []State{OK, KO, KO}, false, Warn, Warn},
+		{"outage confirmed", "api-v1", []State{OK, KO, KO, KO}, false, KO, KO},
+		{"outage confirmed around maintenance", "api-v1", []State{KO, Maintenance, KO, KO}, false, KO, KO},
+		{"pattern not matching", "v1-api", []State{OK, KO, KO}, false, KO, KO},
+		{"recovery in grace", "database", []State{KO, KO, OK}, false, KO, KO},
+		{"recovered after the grace", "database", []State{KO, KO, OK, OK}, false, Warn, Warn},
+		{"error ratio under the limit", "batch", []State{KO, OK, OK, OK}, false, Warn, Warn},
+		{"error ratio over the limit", "batch", []State{KO, KO, KO, OK}, false, Warn, Warn},
+		{"error ratio over the limit, only last", "batch", []State{KO, KO, KO, OK}, true, Warn, Warn},
+		{"error ratio under the limit, only last", "batch", []State{KO, OK, OK, OK}, true, Warn, OK},
+		{"error ratio on the limit, pattern", "api-v2", []State{KO, OK, OK, OK}, true, Warn, OK},
+		{"error ratio over the limit, pattern", "api-v2", []State{KO, OK, KO, OK}, true, Warn, Warn},
+		{"under maintenance", "api-v1", []State{KO, KO, Maintenance}, false, Maintenance, OK},
+	}
+	start := time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := IgnoreConfig{Rules: rules}
+			if test.onlyLast {
+				config.OnlyLastRegexList = []*regexp.Regexp{regexp.MustCompile(".")}
+			}
+			monitor := &Monitor{Name: test.monitor, Status: beats(start, test.states...)}
+			local, global := monitor.analyzeStatus(config)
+			if local != test.wantLocal || global != test.wantGlobal {
+				t.Errorf("analyzeStatus() = %v, %v, want %v, %v", local, global, test.wantLocal, test.wantGlobal)
+			}
+		})
+	}
+
+	// The rules do not apply to the ignored monitors
+	config := IgnoreConfig{Rules: rules, Ignore: []string{"database"}}
+	monitor := &Monitor{Name: "database", Status: beats(start, KO, KO, OK)}
+	if local, global := monitor.analyzeStatus(config); local != Warn || global != OK {
+		t.Errorf("ignored monitor analyzed as %v, %v, want %v, %v", local, global, Warn, OK)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type ServeCmd struct {
+	DashboardArgs `embed:""`
+	Listen        string        `help:"Address the HTTP server listens on" default:":8080"`
+	Interval      time.Duration `help:"Interval between two polls of the dashboards" default:"30s"`
+}
+
+// Server polls the dashboards and serves the last computed reports, so clients never hit kuma directly
+type Server struct {
+	config     Config
+	args       DashboardArgs
+	dashboards []string
+
+	mu          sync.RWMutex
+	reports     map[string]JSONReport
+	errors      map[string]error
+	fetchErrors map[string]int
+}
+
+type JSONMonitorEntry struct {
+	Dashboard string `json:"dashboard"`
+	GroupId   int    `json:"groupId"`
+	GroupName string `json:"groupName"`
+	JSONMonitor
+}
+
+type JSONError struct {
+	Error string `json:"error"`
+}
+
+func NewServer(config Config, args DashboardArgs) *Server {
+	// Every monitor is served, the clients filter by themselves
+	config.KeepAll()
+	return &Server{
+		config:      config,
+		args:        args,
+		reports:     map[string]JSONReport{},
+		errors:      map[string]error{},
+		fetchErrors: map[string]int{},
+	}
+}
+
+func (cmd *ServeCmd) Run(config *Config) error {
+	if cmd.Interval <= 0 {
+		return fmt.Errorf("invalid interval (%s): must be positive", cmd.Interval)
+	}
+
+	server := NewServer(*config, cmd.DashboardArgs)
+	server.Poll()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		ticker := time.NewTicker(cmd.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				server.Poll()
+			}
+		}
+	}()
+
+	httpServer := &http.Server{
+		Addr:    cmd.Listen,
+		Handler: server.Handler(),
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Listening on %s\n", cmd.Listen)
+	err := httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Poll discovers and fetches every dashboard and updates the cache.
+// The last successful report of a dashboard is kept when its fetch fails,
+// as well as the last discovered dashboards when the discovery fails
+func (s *Server) Poll() {
+	reports := map[string]JSONReport{}
+	errs := map[string]error{}
+	dashboards := s.dashboards
+	if CheckAvailability(s.config.Url, s.config.HTTP.GetClient()) {
+		discovered, err := s.args.Dashboards(s.config)
+		if err != nil {
+			fmt.Println(err)
+		} else {
+			dashboards = discovered
+		}
+		for _, result := range FetchAll(s.config, dashboards) {
+			if result.Err != nil {
+				errs[result.Dashboard] = result.Err
+				continue
+			}
+			reports[result.Dashboard] = NewJSONReport(result.Report(s.config))
+		}
+	} else {
+		for _, dash := range dashboards {
+			errs[dash] = fmt.Errorf("not connected to kuma")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dashboards = dashboards
+	for dash, report := range reports {
+		s.reports[dash] = report
+	}
+	for dash := range errs {
+		s.fetchErrors[dash]++
+	}
+	s.errors = errs
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/dashboards", s.handleDashboards)
+	mux.HandleFunc("GET /api/dashboards/{slug}", s.handleDashboard)
+	mux.HandleFunc("GET /api/monitors/{id}", s.handleMonitor)
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	return mux
+}
+
+// report returns the cached report of the dashboard, with the error of the last poll if it failed
+func (s *Server) report(dash string) (JSONReport, bool) {
+	report, ok := s.reports[dash]
+	if !ok {
+		return JSONReport{}, false
+	}
+	if err, failed := s.errors[dash]; failed {
+		report.Error = err.Error()
+	}
+	return report, true
+}
+
+func (s *Server) handleDashboards(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reports := []JSONReport{}
+	for _, dash := range s.dashboards {
+		report, ok := s.report(dash)
+		if !ok {
+			report = JSONReport{
+				Dashboard: dash,
+				Groups:    []JSONGroup{},
+				Error:     "not fetched yet",
+			}
+			if err := s.errors[dash]; err != nil {
+				report.Error = err.Error()
+			}
+		}
+		reports = append(reports, report)
+	}
+	writeJSON(w, http.StatusOK, reports)
+}
+
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	report, ok := s.report(slug)
+	if ok {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+	if err, failed := s.errors[slug]; failed {
+		writeJSON(w, http.StatusServiceUnavailable, JSONError{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusNotFound, JSONError{Error: fmt.Sprintf("unknown dashboard %s", slug)})
+}
+
+func (s *Server) handleMonitor(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, dash := range s.dashboards {
+		report, ok := s.report(dash)
+		if !ok {
+			continue
+		}
+		for _, group := range report.Groups {
+			for _, monitor := range group.Monitors {
+				if monitor.Id != id {
+					continue
+				}
+				entry := JSONMonitorEntry{
+					Dashboard:   dash,
+					GroupId:     group.Id,
+					GroupName:   group.Name,
+					JSONMonitor: monitor,
+				}
+				writeJSON(w, http.StatusOK, entry)
+				return
+			}
+		}
+	}
+	writeJSON(w, http.StatusNotFound, JSONError{Error: fmt.Sprintf("unknown monitor %s", id)})
+}
+
+// handleHealth returns 503 when a dashboard is KO or has never been fetched, 200 otherwise
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	globalState := OK
+	for _, dash := range s.dashboards {
+		report, ok := s.report(dash)
+		if !ok {
+			globalState = KO
+			break
+		}
+		globalState = globalState.Min(report.GlobalState)
+	}
+
+	status := http.StatusOK
+	if globalState == KO {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, globalState.String())
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var reports []JSONReport
+	for _, dash := range s.dashboards {
+		report, ok := s.report(dash)
+		if !ok {
+			report = JSONReport{Dashboard: dash, Error: "not fetched yet"}
+		}
+		reports = append(reports, report)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = WritePrometheus(w, reports, s.fetchErrors)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/containrrr/shoutrrr/pkg/services/slack"
+)
+
+const (
+	slackPostMessage = "https://slack.com/api/chat.postMessage"
+	// slackHeaderLimit and slackSectionLimit are the maximum lengths of the texts of the Block Kit blocks
+	slackHeaderLimit  = 150
+	slackSectionLimit = 3000
+)
+
+// SlackMessage is a Block Kit message, the groups being rendered as colored attachments
+type SlackMessage struct {
+	Text        string            `json:"text"`
+	Channel     string            `json:"channel,omitempty"`
+	Blocks      []SlackBlock      `json:"blocks,omitempty"`
+	Attachments []SlackAttachment `json:"attachments,omitempty"`
+}
+
+type SlackBlock struct {
+	Type string     `json:"type"`
+	Text *SlackText `json:"text,omitempty"`
+}
+
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type SlackAttachment struct {
+	Color  string       `json:"color,omitempty"`
+	Blocks []SlackBlock `json:"blocks"`
+}
+
+// slackColor converts a notification color (0xRRGGBB) to the format expected by slack (#RRGGBB)
+func slackColor(color string) string {
+	if color == "" {
+		return ""
+	}
+	return "#" + strings.TrimPrefix(color, "0x")
+}
+
+// slackEscape escapes the control characters of the slack mrkdwn format
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// slackSections splits the lines into sections, so that each of them stays below the slack limit
+func slackSections(lines []string) []SlackBlock {
+	var sections []SlackBlock
+	sb := strings.Builder{}
+	flush := func() {
+		if sb.Len() > 0 {
+			sections = append(sections, SlackBlock{
+				Type: "section",
+				Text: &SlackText{Type: "mrkdwn", Text: strings.TrimSuffix(sb.String(), "\n")},
+			})
+			sb.Reset()
+		}
+	}
+	for _, line := range lines {
+		line = TruncateMessage(line, slackSectionLimit-1)
+		if sb.Len()+len(line)+1 > slackSectionLimit {
+			flush()
+		}
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	flush()
+	return sections
+}
+
+// monitorLines returns the lines describing the monitor, using emoji instead of ANSI colors
+func monitorLines(monitor ParsedMonitor) []string {
+	line := fmt.Sprintf("%s *%s*", monitor.Emoji, slackEscape(strings.TrimSpace(removeANSICodes(monitor.Name))))
+	if monitor.Uptime != "" {
+		line = fmt.Sprintf("%s (%s)", line, monitor.Uptime)
+	}
+	lines := []string{line}
+	for _, detail := range []string{monitor.Failure, monitor.Downtime} {
+		if detail != "" {
+			lines = append(lines, fmt.Sprintf("↳ %s", slackEscape(detail)))
+		}
+	}
+	for _, detail := range []string{monitor.Latency, monitor.Details, monitor.EmojiBeats} {
+		if detail = strings.TrimSpace(removeANSICodes(detail)); detail != "" {
+			lines = append(lines, slackEscape(detail))
+		}
+	}
+	return lines
+}
+
+// RenderSlack renders the content as a Block Kit message: the dashboard as header,
+// then the incident and each group as an attachment colored according to its state
+func RenderSlack(content Content, config Config) SlackMessage {
+	dashboard := strings.TrimSpace(strings.SplitN(content.Header, "\n", 2)[0])
+	message := SlackMessage{
+		Text: dashboard,
+	}
+	if dashboard != "" {
+		message.Blocks = append(message.Blocks, SlackBlock{
+			Type: "header",
+			Text: &SlackText{Type: "plain_text", Text: TruncateMessage(dashboard, slackHeaderLimit)},
+		})
+	}
+
+	if content.Incident != nil {
+		var lines []string
+		for i, line := range content.Incident.Lines() {
+			line = slackEscape(line)
+			if i == 0 {
+				line = fmt.Sprintf("%s *%s*", config.Symbol.Incident, line)
+			}
+			lines = append(lines, line)
+		}
+		message.Attachments = append(message.Attachments, SlackAttachment{
+			Color:  slackColor(content.Incident.NotificationColor()),
+			Blocks: slackSections(lines),
+		})
+	}
+
+	for _, group := range content.Content {
+		colored := NewColoredStringBuilder()
+		lines := []string{fmt.Sprintf("*%s*", slackEscape(removeANSICodes(group.GroupName)))}
+		for _, monitor := range group.Monitors {
+			colored.Colorize(monitor.State)
+			lines = append(lines, monitorLines(monitor)...)
+		}
+		message.Attachments = append(message.Attachments, SlackAttachment{
+			Color:  slackColor(colored.Color()),
+			Blocks: slackSections(lines),
+		})
+	}
+	return message
+}
+
+// SendSlack posts the message using the webhook or the bot token of the slack URL
+func SendSlack(client *http.Client, conf *slack.Config, message SlackMessage) error {
+	endpoint := conf.Token.WebhookURL()
+	if conf.Token.IsAPIToken() {
+		endpoint = slackPostMessage
+		message.Channel = conf.Channel
+	}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if conf.Token.IsAPIToken() {
+		req.Header.Set("Authorization", conf.Token.Authorization())
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send slack notification: %w", err)
+	}
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	if !conf.Token.IsAPIToken() {
+		if r.StatusCode != http.StatusOK {
+			return statusError{fmt.Errorf("unable to send slack notification: %s %s", r.Status, strings.TrimSpace(string(body))), r.StatusCode}
+		}
+		return nil
+	}
+	if r.StatusCode == http.StatusTooManyRequests || r.StatusCode >= 500 {
+		return statusError{fmt.Errorf("unable to send slack notification: %s", r.Status), r.StatusCode}
+	}
+	// The API answers 200 with the error in its body
+	response := struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("unable to send slack notification: %s", err)
+	}
+	if !response.Ok {
+		err := fmt.Errorf("unable to send slack notification: %s", response.Error)
+		if response.Error == "ratelimited" {
+			return err
+		}
+		// The token or the channel is rejected
+		return permanentError{err}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+const fenceClose = "```\n"
+
+// MessageLimits are the limits of the messages of a service, 0 meaning no limit
+type MessageLimits struct {
+	// Chars is the maximum length of a message
+	Chars int
+	// Lines is the maximum number of lines of a message, each line being sent as a distinct embed
+	Lines int
+	// Blocks is the maximum number of Block Kit blocks of a message
+	Blocks int
+}
+
+// ServiceLimits returns the limits of the service of the notification URL
+func ServiceLimits(service string, notifyUrl *url.URL) MessageLimits {
+	switch service {
+	case "discord":
+		limits := MessageLimits{Chars: 2000}
+		// Unless splitlines=no, shoutrrr sends each line as an embed, and discord accepts 10 embeds per message
+		if !strings.EqualFold(notifyUrl.Query().Get("splitlines"), "no") {
+			limits.Lines = 10
+		}
+		return limits
+	case "slack":
+		return MessageLimits{Blocks: 50}
+	case "telegram":
+		return MessageLimits{Chars: 4096}
+	}
+	return MessageLimits{Chars: 2000}
+}
+
+// messagePart is a piece of a notification that is never split across messages, unless it exceeds the limits on its own
+type messagePart struct {
+	Text  string
+	State State
+}
+
+// fenceAfter returns the opening line of the code fence still open after the text, "" if none
+func fenceAfter(fence string, text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if fence == "" {
+				fence = line + "\n"
+			} else {
+				fence = ""
+			}
+		}
+	}
+	return fence
+}
+
+// fits returns whether the text can be added to the message, the fence left open being closed afterward
+func (l MessageLimits) fits(message string, text string, fence string) bool {
+	closing := ""
+	if fence != "" {
+		closing = fenceClose
+	}
+	if l.Chars > 0 && len(message)+len(text)+len(closing) > l.Chars {
+		return false
+	}
+	if l.Lines > 0 && strings.Count(message+text+closing, "\n") > l.Lines {
+		return false
+	}
+	return true
+}
+
+// truncate shortens the text until it fits in an empty message: the lines that fit are kept whole,
+// the next one is cut character by character, and an ellipsis marks the cut
+func (l MessageLimits) truncate(prefix string, text string, fence string) string {
+	kept := ""
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if l.fits(prefix, kept+line+"…\n", fenceAfter(fence, kept+line)) {
+			kept += line
+			continue
+		}
+		runes := []rune(strings.TrimSuffix(line, "\n"))
+		for len(runes) > 0 && !l.fits(prefix, kept+string(runes)+"…\n", fenceAfter(fence, kept+string(runes))) {
+			runes = runes[:len(runes)-1]
+		}
+		return kept + string(runes) + "…\n"
+	}
+	return kept
+}
+
+// PackMessages packs as many parts as possible into each message, within the limits of the service.
+// A code fence open when a message is split is closed at the end of the message, and reopened in the next one
+func PackMessages(parts []messagePart, limits MessageLimits) []ColoredStringBuilder {
+	var messages []ColoredStringBuilder
+	message := NewColoredStringBuilder()
+	// reopened is the content of the message when it only holds the fence reopened from the previous one
+	reopened := ""
+	fence := ""
+	for _, part := range parts {
+		after := fenceAfter(fence, part.Text)
+		if message.Len() > len(reopened) && !limits.fits(message.String(), part.Text, after) {
+			if fence != "" {
+				message.WriteString(fenceClose)
+			}
+			messages = append(messages, message)
+			message = NewColoredStringBuilder()
+			message.WriteString(fence)
+			reopened = fence
+		}
+		text := part.Text
+		if !limits.fits(message.String(), text, after) {
+			text = limits.truncate(message.String(), text, fence)
+			after = fenceAfter(fence, text)
+		}
+		message.WriteString(text)
+		message.Colorize(part.State)
+		fence = after
+	}
+	if message.Len() > len(reopened) {
+		if fence != "" {
+			message.WriteString(fenceClose)
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// PackSlack splits the attachments of the message so that each message holds at most the maximum number of blocks,
+// the header being repeated in each message
+func PackSlack(message SlackMessage, limits MessageLimits) []SlackMessage {
+	if limits.Blocks <= len(message.Blocks) {
+		return []SlackMessage{message}
+	}
+	var messages []SlackMessage
+	current := SlackMessage{Text: message.Text, Blocks: message.Blocks}
+	count := len(message.Blocks)
+	for _, attachment := range message.Attachments {
+		blocks := attachment.Blocks
+		for len(blocks) > 0 {
+			if count >= limits.Blocks {
+				messages = append(messages, current)
+				current = SlackMessage{Text: message.Text, Blocks: message.Blocks}
+				count = len(message.Blocks)
+			}
+			take := min(limits.Blocks-count, len(blocks))
+			current.Attachments = append(current.Attachments, SlackAttachment{
+				Color:  attachment.Color,
+				Blocks: blocks[:take],
+			})
+			count += take
+			blocks = blocks[take:]
+		}
+	}
+	if len(current.Attachments) > 0 || len(messages) == 0 {
+		messages = append(messages, current)
+	}
+	return messages
+}
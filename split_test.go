@@ -0,0 +1,154 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func messageTexts(messages []ColoredStringBuilder) []string {
+	var texts []string
+	for _, message := range messages {
+		texts = append(texts, message.String())
+	}
+	return texts
+}
+
+func TestPackMessages(t *testing.T) {
+	tests := []struct {
+		name   string
+		parts  []string
+		limits MessageLimits
+		want   []string
+	}{
+		{
+			name:   "no limit",
+			parts:  []string{"a\n", "b\n"},
+			limits: MessageLimits{},
+			want:   []string{"a\nb\n"},
+		},
+		{
+			name:   "chars",
+			parts:  []string{"aaaa\n", "bbbb\n", "cccc\n"},
+			limits: MessageLimits{Chars: 10},
+			want:   []string{"aaaa\nbbbb\n", "cccc\n"},
+		},
+		{
+			name:   "lines",
+			parts:  []string{"a\n", "b\n", "c\n", "d\n", "e\n"},
+			limits: MessageLimits{Lines: 2},
+			want:   []string{"a\nb\n", "c\nd\n", "e\n"},
+		},
+		{
+			name:   "part kept whole",
+			parts:  []string{"a\n", "b\nc\n", "d\n"},
+			limits: MessageLimits{Lines: 2},
+			want:   []string{"a\n", "b\nc\n", "d\n"},
+		},
+		{
+			name:   "fence closed and reopened",
+			parts:  []string{"```ansi\n", "aaaa\n", "bbbb\n", "```\n"},
+			limits: MessageLimits{Chars: 20},
+			want:   []string{"```ansi\naaaa\n```\n", "```ansi\nbbbb\n```\n"},
+		},
+		{
+			name:   "lines truncated",
+			parts:  []string{"a\nb\nc\nd\n"},
+			limits: MessageLimits{Lines: 2},
+			want:   []string{"a\nb…\n"},
+		},
+		{
+			name:   "long line cut",
+			parts:  []string{"### Web\n", "🔥 frontend-with-a-very-long-name\n"},
+			limits: MessageLimits{Chars: 24},
+			want:   []string{"### Web\n", "🔥 frontend-with-a…\n"},
+		},
+		{
+			name:   "group header kept with the cut monitor",
+			parts:  []string{"### Web\n🔥 frontend-with-a-very-long-name\n"},
+			limits: MessageLimits{Chars: 24},
+			want:   []string{"### Web\n🔥 fronten…\n"},
+		},
+		{
+			name:   "long line cut in a fence",
+			parts:  []string{"```\n0123456789abcdef\n", "```\n"},
+			limits: MessageLimits{Chars: 20},
+			want:   []string{"```\n01234567…\n```\n"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var parts []messagePart
+			for _, text := range test.parts {
+				parts = append(parts, messagePart{Text: text, State: OK})
+			}
+			got := messageTexts(PackMessages(parts, test.limits))
+			if strings.Join(got, "|") != strings.Join(test.want, "|") {
+				t.Fatalf("PackMessages() = %q, want %q", got, test.want)
+			}
+			for _, message := range got {
+				if test.limits.Chars > 0 && len(message) > test.limits.Chars {
+					t.Errorf("message %q exceeds %d chars", message, test.limits.Chars)
+				}
+				if test.limits.Lines > 0 && strings.Count(message, "\n") > test.limits.Lines {
+					t.Errorf("message %q exceeds %d lines", message, test.limits.Lines)
+				}
+				if !utf8.ValidString(message) {
+					t.Errorf("message %q is not valid UTF-8", message)
+				}
+			}
+		})
+	}
+}
+
+func TestPackMessagesColor(t *testing.T) {
+	parts := []messagePart{{Text: "a\n", State: OK}, {Text: "b\n", State: KO}, {Text: "c\n", State: Warn}}
+	messages := PackMessages(parts, MessageLimits{Lines: 2})
+	if len(messages) != 2 || messages[0].State != KO || messages[1].State != Warn {
+		t.Errorf("PackMessages() states = %v", messages)
+	}
+}
+
+func TestPackSlack(t *testing.T) {
+	sections := func(n int) []SlackBlock {
+		return make([]SlackBlock, n)
+	}
+	message := SlackMessage{
+		Text:   "dashboard",
+		Blocks: sections(1),
+		Attachments: []SlackAttachment{
+			{Color: "#1", Blocks: sections(3)},
+			{Color: "#2", Blocks: sections(4)},
+		},
+	}
+	tests := []struct {
+		name   string
+		limits MessageLimits
+		// want is the number of blocks of each attachment, per message
+		want [][]int
+	}{
+		{"within the limit", MessageLimits{Blocks: 50}, [][]int{{3, 4}}},
+		{"attachments split", MessageLimits{Blocks: 4}, [][]int{{3}, {3}, {1}}},
+		{"attachments packed", MessageLimits{Blocks: 6}, [][]int{{3, 2}, {2}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messages := PackSlack(message, test.limits)
+			var got [][]int
+			for _, m := range messages {
+				if m.Text != message.Text || len(m.Blocks) != len(message.Blocks) {
+					t.Errorf("message %+v does not repeat the header", m)
+				}
+				blocks := []int{}
+				for _, attachment := range m.Attachments {
+					blocks = append(blocks, len(attachment.Blocks))
+				}
+				got = append(got, blocks)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("PackSlack() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
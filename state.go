@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MonitorState is the state of a monitor persisted between two runs
+type MonitorState struct {
+	Name        string    `json:"name"`
+	LocalState  State     `json:"localState"`
+	GlobalState State     `json:"globalState"`
+	Since       time.Time `json:"since"`
+}
+
+// StateChange describes the transition of the global state of a monitor since the last run
+type StateChange struct {
+	Previous State
+	// Duration is how long the previous state lasted, 0 if unknown
+	Duration time.Duration
+}
+
+func (c *StateChange) String(current State) string {
+	if c.Duration == 0 {
+		return fmt.Sprintf("%s → %s", c.Previous.String(), current.String())
+	}
+	return fmt.Sprintf("%s → %s (%s for %s)", c.Previous.String(), current.String(), c.Previous.String(), FormatDuration(c.Duration))
+}
+
+// StateFile holds the monitors state, keyed by dashboard and monitor id
+type StateFile struct {
+	path       string
+	Dashboards map[string]map[string]MonitorState `json:"dashboards"`
+	// Incidents holds the key of the last incident seen on each dashboard
+	Incidents map[string]string `json:"incidents,omitempty"`
+}
+
+// DefaultStateFilePath returns the state file location used when none is configured
+func DefaultStateFilePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, APP_NAME, "state.json"), nil
+}
+
+// LoadStateFile reads the state file, a missing file is considered as empty
+func LoadStateFile(path string) (*StateFile, error) {
+	states := &StateFile{
+		path:       path,
+		Dashboards: map[string]map[string]MonitorState{},
+		Incidents:  map[string]string{},
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read state file (%s): %s", path, err)
+	}
+	err = json.Unmarshal(data, states)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse state file (%s): %s", path, err)
+	}
+	if states.Dashboards == nil {
+		states.Dashboards = map[string]map[string]MonitorState{}
+	}
+	if states.Incidents == nil {
+		states.Incidents = map[string]string{}
+	}
+	return states, nil
+}
+
+// Save atomically writes the state file
+func (s *StateFile) Save() error {
+	err := os.MkdirAll(filepath.Dir(s.path), 0o755)
+	if err != nil {
+		return fmt.Errorf("unable to create state file directory: %s", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to write state file (%s): %s", s.path, err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Update records the state of the monitors of the report, and returns a report containing only the monitors
+// whose global state changed since the last run, and the incident if it was posted or updated since then.
+// A monitor seen for the first time is considered as previously OK
+func (s *StateFile) Update(report Report, now time.Time) Report {
+	previous := s.Dashboards[report.Dashboard]
+	current := map[string]MonitorState{}
+
+	changes := Report{
+		Dashboard:   report.Dashboard,
+		GlobalState: report.GlobalState,
+	}
+	for _, group := range report.Groups {
+		changedGroup := ReportGroup{
+			Group: group.Group,
+		}
+		for _, monitor := range group.Monitors {
+			state := MonitorState{
+				Name:        monitor.Name,
+				LocalState:  monitor.LocalState,
+				GlobalState: monitor.GlobalState,
+				Since:       now,
+			}
+			old, known := previous[monitor.Id]
+			if !known {
+				old = MonitorState{GlobalState: OK}
+			}
+
+			if known && old.GlobalState == monitor.GlobalState {
+				state.Since = old.Since
+			}
+			current[monitor.Id] = state
+
+			if old.GlobalState == monitor.GlobalState {
+				continue
+			}
+			change := &StateChange{
+				Previous: old.GlobalState,
+			}
+			if known {
+				change.Duration = now.Sub(old.Since)
+			}
+			monitor.Change = change
+			changedGroup.Monitors = append(changedGroup.Monitors, monitor)
+		}
+		if len(changedGroup.Monitors) > 0 {
+			changes.Groups = append(changes.Groups, changedGroup)
+		}
+	}
+	s.Dashboards[report.Dashboard] = current
+
+	key := ""
+	if report.Incident != nil {
+		key = report.Incident.Key()
+	}
+	if key != "" && s.Incidents[report.Dashboard] != key {
+		changes.Incident = report.Incident
+	}
+	if key == "" {
+		delete(s.Incidents, report.Dashboard)
+	} else {
+		s.Incidents[report.Dashboard] = key
+	}
+	return changes
+}
+
+// FormatDuration formats a duration in a compact human-readable way (3d4h, 2h15m, 42m, 30s)
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	seconds := (d % time.Minute) / time.Second
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
+
+// Restore reverts the state of the dashboard to the one it had before its update
+func (s *StateFile) Restore(dashboard string, monitors map[string]MonitorState, incident string) {
+	if monitors == nil {
+		delete(s.Dashboards, dashboard)
+	} else {
+		s.Dashboards[dashboard] = monitors
+	}
+	if incident == "" {
+		delete(s.Incidents, dashboard)
+	} else {
+		s.Incidents[dashboard] = incident
+	}
+}
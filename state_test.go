@@ -0,0 +1,170 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func stateReport(incident *Incident, states map[string]State) Report {
+	group := ReportGroup{Group: Group{Id: 1, Name: "Web"}}
+	for _, id := range []string{"1", "2", "3"} {
+		state, ok := states[id]
+		if !ok {
+			continue
+		}
+		group.Monitors = append(group.Monitors, ReportMonitor{
+			Monitor:     &Monitor{Id: id, Name: "monitor " + id},
+			LocalState:  state,
+			GlobalState: state,
+		})
+	}
+	return Report{Dashboard: "prod", GlobalState: OK, Groups: []ReportGroup{group}, Incident: incident}
+}
+
+// changedMonitors returns the change of the monitors reported as changed, keyed by id
+func changedMonitors(report Report) map[string]StateChange {
+	changes := map[string]StateChange{}
+	for _, group := range report.Groups {
+		for _, monitor := range group.Monitors {
+			changes[monitor.Id] = *monitor.Change
+		}
+	}
+	return changes
+}
+
+// previousStates returns the previous state of the monitors reported as changed, keyed by id
+func previousStates(report Report) map[string]State {
+	previous := map[string]State{}
+	for id, change := range changedMonitors(report) {
+		previous[id] = change.Previous
+	}
+	return previous
+}
+
+func TestStateFileUpdate(t *testing.T) {
+	start := time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC)
+	incident := &Incident{Id: 1, Title: "Maintenance", CreatedDate: StatusTime(start)}
+	lastUpdate := StatusTime(start.Add(4 * time.Minute))
+	updated := &Incident{Id: 1, Title: "Maintenance", CreatedDate: StatusTime(start), LastUpdatedDate: &lastUpdate}
+	runs := []struct {
+		name      string
+		states    map[string]State
+		incident  *Incident
+		want      map[string]StateChange
+		incident2 bool
+	}{
+		{
+			name:   "first run, the new monitors are compared to OK",
+			states: map[string]State{"1": OK, "2": KO},
+			want:   map[string]StateChange{"2": {Previous: OK}},
+		},
+		{
+			name:   "nothing changed",
+			states: map[string]State{"1": OK, "2": KO},
+			want:   map[string]StateChange{},
+		},
+		{
+			name:   "recovery, with the duration of the outage",
+			states: map[string]State{"1": OK, "2": OK},
+			want:   map[string]StateChange{"2": {Previous: KO, Duration: 2 * time.Minute}},
+		},
+		{
+			name:      "incident posted, new monitor",
+			states:    map[string]State{"1": Warn, "2": OK, "3": OK},
+			incident:  incident,
+			want:      map[string]StateChange{"1": {Previous: OK, Duration: 3 * time.Minute}},
+			incident2: true,
+		},
+		{
+			name:     "incident unchanged",
+			states:   map[string]State{"1": Warn, "2": OK, "3": OK},
+			incident: incident,
+			want:     map[string]StateChange{},
+		},
+		{
+			name:      "incident updated",
+			states:    map[string]State{"1": Warn, "2": OK, "3": OK},
+			incident:  updated,
+			want:      map[string]StateChange{},
+			incident2: true,
+		},
+	}
+
+	states, err := LoadStateFile(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, run := range runs {
+		changes := states.Update(stateReport(run.incident, run.states), start.Add(time.Duration(i)*time.Minute))
+		if got := changedMonitors(changes); !reflect.DeepEqual(got, run.want) {
+			t.Errorf("%s: changes = %+v, want %+v", run.name, got, run.want)
+		}
+		if (changes.Incident != nil) != run.incident2 {
+			t.Errorf("%s: incident reported = %v, want %v", run.name, changes.Incident != nil, run.incident2)
+		}
+	}
+
+	// The states survive a reload
+	if err := states.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadStateFile(states.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reloaded.Dashboards, states.Dashboards) || !reflect.DeepEqual(reloaded.Incidents, states.Incidents) {
+		t.Errorf("reloaded = %+v, want %+v", reloaded, states)
+	}
+	if since := reloaded.Dashboards["prod"]["1"].Since; !since.Equal(start.Add(3 * time.Minute)) {
+		t.Errorf("monitor 1 warn since %s, want %s", since, start.Add(3*time.Minute))
+	}
+}
+
+func TestStateFileRestore(t *testing.T) {
+	now := time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC)
+	incident := &Incident{Id: 1, Title: "Maintenance", CreatedDate: StatusTime(now)}
+	tests := []struct {
+		name    string
+		initial []Report
+	}{
+		{"unknown dashboard", nil},
+		{"known dashboard", []Report{stateReport(nil, map[string]State{"1": OK, "2": KO})}},
+		{"known incident", []Report{stateReport(incident, map[string]State{"1": OK})}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			states, err := LoadStateFile(filepath.Join(t.TempDir(), "state.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, report := range test.initial {
+				states.Update(report, now)
+			}
+			previous, previousIncident := states.Dashboards["prod"], states.Incidents["prod"]
+			wantDashboards := map[string]map[string]MonitorState{}
+			for dashboard, monitors := range states.Dashboards {
+				wantDashboards[dashboard] = monitors
+			}
+
+			changes := states.Update(stateReport(nil, map[string]State{"1": KO, "2": OK, "3": Warn}), now.Add(time.Hour))
+			if len(changes.Groups) == 0 {
+				t.Fatal("no change reported")
+			}
+			states.Restore("prod", previous, previousIncident)
+
+			if !reflect.DeepEqual(states.Dashboards, wantDashboards) {
+				t.Errorf("dashboards = %+v, want %+v", states.Dashboards, wantDashboards)
+			}
+			if states.Incidents["prod"] != previousIncident {
+				t.Errorf("incident = %q, want %q", states.Incidents["prod"], previousIncident)
+			}
+			// The changes are reported again once restored
+			again := states.Update(stateReport(nil, map[string]State{"1": KO, "2": OK, "3": Warn}), now.Add(2*time.Hour))
+			if !reflect.DeepEqual(previousStates(again), previousStates(changes)) {
+				t.Errorf("changes after restore = %+v, want %+v", previousStates(again), previousStates(changes))
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/alecthomas/kong"
+)
+
+type TemplateConfig struct {
+	Template         string            `help:"Template of the notifications (Go text/template), inline or path of a file"`
+	TemplateOverride map[string]string `help:"Template per notification URL or service (e.g. slack=path), inline or path of a file"`
+	// templates are the compiled templates, keyed by notification URL or service, the default one by ""
+	templates map[string]*template.Template
+}
+
+// TemplateData is the data model the notification templates are rendered with
+type TemplateData struct {
+	Dashboard string
+	// State is the global state of the dashboard (OK, WARN, KO...), and Emoji its symbol
+	State    string
+	Emoji    string
+	Incident *Incident
+	Groups   []TemplateGroup
+	// Now is the date of the notification
+	Now time.Time
+}
+
+type TemplateGroup struct {
+	Name string
+	// State is the most severe state of the monitors of the group
+	State    string
+	Monitors []TemplateMonitor
+}
+
+type TemplateMonitor struct {
+	Name  string
+	State string
+	Emoji string
+	// Beats are the beats of the monitor rendered as emoji
+	Beats string
+	// LastMessage is the last failure message of the monitor, LastMessageSince the start of the failure
+	LastMessage      string
+	LastMessageSince time.Time
+	// Since is the date of the first beat of the current state
+	Since time.Time
+	// Downtime is the time spent down over the beats, during Outages outages
+	Downtime time.Duration
+	Outages  int
+	// Uptime24h and Uptime30d are ratios, nil when kuma does not provide them
+	Uptime    string
+	Uptime24h *float64
+	Uptime30d *float64
+	Latency   string
+	// Change describes the state change, when notifying on change
+	Change string
+}
+
+// templateFuncs are the helpers available in the notification templates
+func templateFuncs(symbol Symbol) template.FuncMap {
+	return template.FuncMap{
+		// color returns the notification color (0xRRGGBB) of a state
+		"color": func(state string) string {
+			csb := NewColoredStringBuilder()
+			csb.State = colorState(stateNamed(state))
+			return csb.Color()
+		},
+		// ansi colors the text with a terminal color name (red, green...)
+		"ansi": func(color string, text string) string {
+			return fmt.Sprintf("\u001B[%dm%s\u001B[0m", colors[color], text)
+		},
+		// symbol returns the symbol of a state, as configured with the icon options
+		"symbol": func(state string) string {
+			return symbol.Get(stateNamed(state))
+		},
+		"truncate": func(length int, s string) string {
+			return TruncateMessage(s, length)
+		},
+		"duration": FormatDuration,
+		"since": func(t time.Time) string {
+			return FormatDuration(time.Since(t))
+		},
+		"date": func(t time.Time) string {
+			return FormatSince(t, time.Now())
+		},
+		"percent": func(ratio *float64) string {
+			if ratio == nil {
+				return ""
+			}
+			return FormatPercent(*ratio)
+		},
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}
+}
+
+// stateNamed returns the state of the given name, Unknown when no state matches
+func stateNamed(name string) State {
+	for _, state := range []State{KO, Warn, OK, WarnOk, Ignored, Maintenance, Pending, Flapping} {
+		if strings.EqualFold(state.String(), name) {
+			return state
+		}
+	}
+	return Unknown
+}
+
+// Compile parses the default notification template, and the templates overriding it per URL or service.
+// Each template is given inline, or as the path of the file holding it
+func (t *TemplateConfig) Compile(symbol Symbol) error {
+	sources := map[string]string{}
+	if t.Template != "" {
+		sources[""] = t.Template
+	}
+	for key, source := range t.TemplateOverride {
+		sources[key] = source
+	}
+
+	t.templates = map[string]*template.Template{}
+	for key, source := range sources {
+		if path := kong.ExpandPath(source); isFile(path) {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("unable to read notification template (%s): %s", path, err)
+			}
+			source = string(content)
+		} else if looksLikePath(source) {
+			return fmt.Errorf("unable to read notification template (%s): no such file", path)
+		}
+		tmpl, err := template.New(key).Funcs(templateFuncs(symbol)).Parse(source)
+		if err != nil {
+			return fmt.Errorf("invalid notification template: %s", err)
+		}
+		t.templates[key] = tmpl
+	}
+	return nil
+}
+
+// isFile returns whether the path is an existing file, a template being otherwise given inline
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// looksLikePath returns whether the template is rather the path of a missing file than an inline template:
+// a single line without any action, holding a path separator or ending with the .tmpl extension
+func looksLikePath(source string) bool {
+	if strings.Contains(source, "{{") || strings.Contains(source, "\n") {
+		return false
+	}
+	return strings.ContainsRune(source, '/') || strings.ContainsRune(source, filepath.Separator) || strings.HasSuffix(source, ".tmpl")
+}
+
+// Get returns the template of the notification URL, falling back on the template of its service,
+// then on the default one. It returns nil when the built-in rendering is used
+func (t *TemplateConfig) Get(notifyUrl string, service string) *template.Template {
+	for _, key := range []string{notifyUrl, service, ""} {
+		if tmpl, ok := t.templates[key]; ok {
+			return tmpl
+		}
+	}
+	return nil
+}
+
+// NewTemplateData builds the data model of the templates from the rendered content
+func NewTemplateData(content Content, config Config) TemplateData {
+	now := time.Now()
+	data := TemplateData{
+		Dashboard: strings.TrimSpace(strings.SplitN(content.Header, "\n", 2)[0]),
+		State:     content.State.String(),
+		Emoji:     config.Symbol.Get(content.State),
+		Incident:  content.Incident,
+		Now:       now,
+	}
+	for _, group := range content.Content {
+		groupState := OK
+		templateGroup := TemplateGroup{
+			Name: removeANSICodes(group.GroupName),
+		}
+		for _, parsed := range group.Monitors {
+			groupState = groupState.Min(parsed.State)
+			monitor := TemplateMonitor{
+				Name:    strings.TrimSpace(removeANSICodes(parsed.Name)),
+				State:   parsed.State.String(),
+				Emoji:   parsed.Emoji,
+				Beats:   strings.TrimSpace(parsed.EmojiBeats),
+				Uptime:  parsed.Uptime,
+				Latency: parsed.Latency,
+				Change:  parsed.Details,
+			}
+			if m := parsed.Monitor.Monitor; m != nil {
+				if failure := m.LastFailure(); failure != nil {
+					monitor.LastMessage = failure.Msg
+					monitor.LastMessageSince = failure.Since
+				}
+				downtime := m.Downtime(now)
+				monitor.Since = downtime.Since
+				monitor.Downtime = downtime.Duration
+				monitor.Outages = downtime.Outages
+				monitor.Uptime24h = m.Uptime.Day
+				monitor.Uptime30d = m.Uptime.Month
+			}
+			templateGroup.Monitors = append(templateGroup.Monitors, monitor)
+		}
+		templateGroup.State = groupState.String()
+		data.Groups = append(data.Groups, templateGroup)
+	}
+	return data
+}
+
+// RenderTemplate renders the content using the template
+func RenderTemplate(tmpl *template.Template, content Content, config Config) (string, error) {
+	sb := strings.Builder{}
+	err := tmpl.Execute(&sb, NewTemplateData(content, config))
+	if err != nil {
+		return "", fmt.Errorf("unable to render notification template: %s", err)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplateCompile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notify.tmpl")
+	if err := os.WriteFile(file, []byte("{{ .Dashboard }} from file"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		err      string
+	}{
+		{"inline", "{{ .Dashboard }} is {{ .State }}", "prod is OK", ""},
+		{"inline with a slash", "{{ .Dashboard }} / {{ .State }}", "prod / OK", ""},
+		{"inline text", "Dashboard updated", "Dashboard updated", ""},
+		{"file", file, "prod from file", ""},
+		{"missing file", filepath.Join(dir, "missing.tmpl"), "", "no such file"},
+		{"missing file without extension", filepath.Join(dir, "notify"), "", "no such file"},
+		{"missing file in the current directory", "notify.tmpl", "", "no such file"},
+		{"directory", dir, "", "no such file"},
+		{"invalid template", "{{ .Dashboard ", "", "invalid notification template"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := TemplateConfig{Template: test.template}
+			err := config.Compile(Symbol{})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("Compile() = %v, want an error containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			sb := strings.Builder{}
+			if err := config.Get("discord://token@id", "discord").Execute(&sb, TemplateData{Dashboard: "prod", State: "OK"}); err != nil {
+				t.Fatal(err)
+			}
+			if sb.String() != test.want {
+				t.Errorf("rendered %q, want %q", sb.String(), test.want)
+			}
+		})
+	}
+}
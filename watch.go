@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	enterAltScreen = "\u001B[?1049h\u001B[?25l"
+	exitAltScreen  = "\u001B[?25h\u001B[?1049l"
+	cursorHome     = "\u001B[H"
+	clearLine      = "\u001B[K"
+	clearScreenEnd = "\u001B[J"
+)
+
+type WatchCmd struct {
+	DashboardArgs `embed:""`
+	Interval      time.Duration `help:"Refresh interval" default:"30s"`
+}
+
+// Run periodically fetches the dashboards and redraws them in place until interrupted
+func (w *WatchCmd) Run(config *Config) error {
+	if w.Interval <= 0 {
+		return fmt.Errorf("invalid interval (%s): must be positive", w.Interval)
+	}
+
+	// Colors are handled by the terminal, xbar formatting is irrelevant here
+	config.Xbar = false
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Print(enterAltScreen)
+	defer fmt.Print(exitAltScreen)
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		// The refresh runs aside so that an interruption does not wait for the requests to time out
+		screen := make(chan string, 1)
+		go func() {
+			screen <- w.Refresh(*config)
+		}()
+		select {
+		case <-ctx.Done():
+			return nil
+		case content := <-screen:
+			w.Draw(content)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh fetches every dashboard and returns the screen content.
+// Fetch errors are displayed in place of the dashboard instead of stopping the watch
+func (w *WatchCmd) Refresh(config Config) string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("Last refresh: %s (every %s)\n\n", time.Now().Format(time.DateTime), w.Interval))
+
+	if !CheckAvailability(config.Url, config.HTTP.GetClient()) {
+		sb.WriteString(fmt.Sprintf("\u001B[%dm%s Dashboard unavailable: not connected to kuma\u001B[0m\n", colors[config.Color.KoBeat], config.Symbol.Error))
+		return sb.String()
+	}
+
+	dashboards, err := w.Dashboards(config)
+	if err != nil {
+		sb.WriteString(fmt.Sprintf("\u001B[%dm%s %s\u001B[0m\n", colors[config.Color.KoBeat], config.Symbol.Error, err))
+		return sb.String()
+	}
+
+	for _, result := range FetchAll(config, dashboards) {
+		var content Content
+		if result.Err != nil {
+			content = RenderError(config, result.Dashboard, result.Err)
+		} else {
+			content = RenderText(config, result.Report(config))
+		}
+		sb.WriteString(content.String())
+		sb.WriteString("\n\n")
+	}
+	return sb.String()
+}
+
+// Draw overwrites the previous screen content, clearing the remaining characters of each line
+// instead of the whole screen to avoid flickering
+func (w *WatchCmd) Draw(screen string) {
+	sb := strings.Builder{}
+	sb.WriteString(cursorHome)
+	for _, line := range strings.Split(strings.TrimRight(screen, "\n"), "\n") {
+		sb.WriteString(line)
+		sb.WriteString(clearLine)
+		sb.WriteString("\n")
+	}
+	sb.WriteString(clearScreenEnd)
+	fmt.Print(sb.String())
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// signatureHeader holds the HMAC-SHA256 of the payload, as sha256=<hex>
+const signatureHeader = "X-Kumago-Signature"
+
+type WebhookConfig struct {
+	Url     []string          `help:"URL of the webhooks receiving the analysis of the dashboards as JSON"`
+	Secret  string            `help:"Secret used to sign the payloads with HMAC-SHA256, in the X-Kumago-Signature header"`
+	Header  map[string]string `help:"Extra headers sent with each webhook request (Name=value)"`
+	Timeout time.Duration     `help:"Timeout of each webhook request" default:"10s"`
+	Retries int               `help:"Number of retries of the requests failing with a transient error" default:"2"`
+	Backoff time.Duration     `help:"Delay before the first retry, doubled at each retry" default:"1s"`
+	// transport is shared with the requests made to kuma, the default one being used when unset
+	transport http.RoundTripper
+}
+
+// WebhookPayload is the document posted to the webhooks
+type WebhookPayload struct {
+	Dashboard   string    `json:"dashboard"`
+	GlobalState State     `json:"globalState"`
+	Date        time.Time `json:"date"`
+	Incident    *Incident `json:"incident,omitempty"`
+	// Changed holds the monitors whose state changed since the last run, when notifying on change
+	Changed []JSONGroup `json:"changed,omitempty"`
+	// Groups holds every monitor of the dashboard, whatever the status filter
+	Groups []JSONGroup `json:"groups"`
+}
+
+// NewWebhookPayload builds the payload of the dashboard, changes being nil when the changes are not tracked
+func NewWebhookPayload(report Report, changes *Report) WebhookPayload {
+	payload := WebhookPayload{
+		Dashboard:   report.Dashboard,
+		GlobalState: report.GlobalState,
+		Date:        time.Now(),
+		Incident:    report.Incident,
+		Groups:      NewJSONReport(report).Groups,
+	}
+	if changes != nil {
+		payload.Changed = NewJSONReport(*changes).Groups
+	}
+	return payload
+}
+
+// Sign returns the signature of the body, as sent in the X-Kumago-Signature header
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send posts the payload to each webhook, the requests failing with a transient error being retried like the
+// notifications. It returns the result of the delivery to each webhook
+func (w *WebhookConfig) Send(payload WebhookPayload) Deliveries {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Deliveries{{Url: strings.Join(w.Url, ","), Errors: []error{err}}}
+	}
+	base := w.transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client := &http.Client{
+		Transport: &RetryTransport{
+			Base:    base,
+			Headers: w.Header,
+			Timeout: w.Timeout,
+		},
+	}
+
+	var deliveries Deliveries
+	for _, webhookUrl := range w.Url {
+		delivery := Delivery{Url: webhookUrl}
+		err := retry(w.Retries, w.Backoff, func() error {
+			return w.post(client, webhookUrl, body)
+		})
+		if err != nil {
+			delivery.Errors = []error{err}
+		} else {
+			delivery.Sent = 1
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries
+}
+
+func (w *WebhookConfig) post(client *http.Client, webhookUrl string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhookUrl, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{fmt.Errorf("invalid webhook url: %s", err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("%s/%s", APP_NAME, Version))
+	if w.Secret != "" {
+		req.Header.Set(signatureHeader, Sign(w.Secret, body))
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send webhook: %w", err)
+	}
+	defer r.Body.Close()
+	response, _ := io.ReadAll(io.LimitReader(r.Body, 512))
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		return statusError{fmt.Errorf("unable to send webhook: %s %s", r.Status, strings.TrimSpace(string(response))), r.StatusCode}
+	}
+	return nil
+}
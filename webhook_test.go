@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret string
+		body   string
+		want   string
+	}{
+		// Reference values computed with: printf '%s' "$body" | openssl dgst -sha256 -hmac "$secret"
+		{"s3cr3t", `{"dashboard":"prod"}`, "sha256=0ea8662882eb6211c3d259a61b7fc71a8c0ee9ccdbe4f60191574e444660cc32"},
+		{"key", "The quick brown fox jumps over the lazy dog", "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{"", "", "sha256=b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad"},
+	}
+	for _, test := range tests {
+		if got := Sign(test.secret, []byte(test.body)); got != test.want {
+			t.Errorf("Sign(%q, %q) = %s, want %s", test.secret, test.body, got, test.want)
+		}
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		failed   bool
+	}{
+		{"delivered", []int{http.StatusNoContent}, 1, false},
+		{"server error retried", []int{http.StatusBadGateway, http.StatusOK}, 2, false},
+		{"rate limit retried", []int{http.StatusTooManyRequests, http.StatusOK}, 2, false},
+		{"client error not retried", []int{http.StatusNotFound, http.StatusOK}, 1, true},
+		{"retries exhausted", []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}, 3, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if signature := r.Header.Get(signatureHeader); signature != Sign("s3cr3t", body) {
+					t.Errorf("signature = %s, want %s", signature, Sign("s3cr3t", body))
+				}
+				if r.Header.Get("X-Extra") != "value" {
+					t.Errorf("extra header not sent")
+				}
+				w.WriteHeader(test.statuses[attempts.Add(1)-1])
+			}))
+			defer server.Close()
+
+			config := WebhookConfig{
+				Url:     []string{server.URL},
+				Secret:  "s3cr3t",
+				Header:  map[string]string{"X-Extra": "value"},
+				Timeout: time.Second,
+				Retries: 2,
+				Backoff: time.Millisecond,
+			}
+			deliveries := config.Send(WebhookPayload{Dashboard: "prod"})
+			if int(attempts.Load()) != test.attempts {
+				t.Errorf("%d attempts, want %d", attempts.Load(), test.attempts)
+			}
+			if deliveries.Failed() != test.failed {
+				t.Errorf("deliveries = %+v, want failed = %v", deliveries, test.failed)
+			}
+		})
+	}
+}
+
+func TestWebhookSendTimeout(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	config := WebhookConfig{Url: []string{server.URL}, Timeout: 50 * time.Millisecond, Retries: 1, Backoff: time.Millisecond}
+	deliveries := config.Send(WebhookPayload{Dashboard: "prod"})
+	if attempts.Load() != 2 || deliveries.Failed() {
+		t.Errorf("%d attempts, deliveries = %+v", attempts.Load(), deliveries)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// noData is the state of a bucket without any beat
+const noData State = -1
+
+// severity orders the states of the beats merged in a bucket, the most severe first
+var severity = []State{KO, Warn, Pending, Ignored, Maintenance, OK, Unknown}
+
+// FilterWindow returns the beats dated after the start of the window
+func FilterWindow(statuses []Status, start time.Time) []Status {
+	var filtered []Status
+	for _, status := range statuses {
+		if !time.Time(status.Date).Before(start) {
+			filtered = append(filtered, status)
+		}
+	}
+	return filtered
+}
+
+// Buckets resamples the beats of the window ending now into count buckets of the same duration,
+// so that the columns of every monitor cover the same period.
+// Each bucket has the most severe state of its beats, noData when it has none, and the average of their pings.
+// The beats are returned as is when the window cannot be split into count buckets
+func (m *Monitor) Buckets(window time.Duration, count int, now time.Time) []Status {
+	if count <= 0 || window < time.Duration(count) {
+		return m.Status
+	}
+	start := now.Add(-window)
+	size := window / time.Duration(count)
+	buckets := make([]Status, count)
+	pings := make([]float64, count)
+	pinged := make([]int, count)
+	for i := range buckets {
+		buckets[i] = Status{
+			Status: noData,
+			Date:   StatusTime(start.Add(time.Duration(i) * size)),
+			Code:   -1,
+		}
+	}
+	for _, status := range m.Status {
+		if time.Time(status.Date).Before(start) {
+			continue
+		}
+		i := int(time.Time(status.Date).Sub(start) / size)
+		if i >= count {
+			i = count - 1
+		}
+		if buckets[i].Status == noData || rank(status.Status) < rank(buckets[i].Status) {
+			buckets[i].Status = status.Status
+			buckets[i].Msg = status.Msg
+		}
+		if status.Ping > 0 {
+			pings[i] += status.Ping
+			pinged[i]++
+		}
+	}
+	for i := range buckets {
+		if pinged[i] > 0 {
+			buckets[i].Ping = pings[i] / float64(pinged[i])
+		}
+	}
+	return buckets
+}
+
+func rank(state State) int {
+	for i, s := range severity {
+		if s == state {
+			return i
+		}
+	}
+	return len(severity)
+}
+
+// displayedBeats returns the beats to render, resampled when configured
+func (m *Monitor) displayedBeats(c Config) []Status {
+	if c.Window > 0 && c.WindowBuckets > 0 {
+		return m.Buckets(c.Window, c.WindowBuckets, time.Now())
+	}
+	return m.Status
+}
+
+// FormatWindow formats the duration of a window, without its zero trailing unit (6h, 1d, 1h30m)
+func FormatWindow(window time.Duration) string {
+	formatted := FormatDuration(window)
+	for _, unit := range []string{"0s", "0m", "0h"} {
+		trimmed, found := strings.CutSuffix(formatted, unit)
+		// Only a trailing unit following another one is removed: 6h0m, but not 20m
+		if found && trimmed != "" && (trimmed[len(trimmed)-1] < '0' || trimmed[len(trimmed)-1] > '9') {
+			return trimmed
+		}
+	}
+	return formatted
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilterWindow(t *testing.T) {
+	start := time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC)
+	statuses := beats(start, OK, KO, Warn, OK)
+	tests := []struct {
+		name  string
+		start time.Time
+		want  []Status
+	}{
+		{"every beat", start.Add(-time.Hour), statuses},
+		{"beat on the start of the window", start.Add(time.Minute), statuses[1:]},
+		{"between two beats", start.Add(90 * time.Second), statuses[2:]},
+		{"no beat", start.Add(time.Hour), nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := FilterWindow(statuses, test.start); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("FilterWindow() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	now := time.Date(2024, 5, 2, 15, 0, 0, 0, time.UTC)
+	start := now.Add(-time.Hour)
+	at := func(minutes int, state State, ping float64) Status {
+		return Status{Status: state, Date: StatusTime(start.Add(time.Duration(minutes) * time.Minute)), Ping: ping}
+	}
+	tests := []struct {
+		name     string
+		statuses []Status
+		want     []State
+		pings    []float64
+	}{
+		{
+			name:  "no beat",
+			want:  []State{noData, noData, noData, noData},
+			pings: []float64{0, 0, 0, 0},
+		},
+		{
+			name:     "most severe state, average ping",
+			statuses: []Status{at(1, OK, 10), at(2, KO, 0), at(3, Warn, 30), at(20, Maintenance, 0), at(21, OK, 5)},
+			want:     []State{KO, Maintenance, noData, noData},
+			pings:    []float64{20, 5, 0, 0},
+		},
+		{
+			name:     "beats on the bucket boundaries start the buckets",
+			statuses: []Status{at(0, KO, 0), at(15, Warn, 0), at(30, OK, 0), at(45, Pending, 0)},
+			want:     []State{KO, Warn, OK, Pending},
+			pings:    []float64{0, 0, 0, 0},
+		},
+		{
+			name:     "beats before the window are dropped, the beat of now is in the last bucket",
+			statuses: []Status{at(-1, KO, 0), at(59, OK, 0), at(60, Warn, 0)},
+			want:     []State{noData, noData, noData, Warn},
+			pings:    []float64{0, 0, 0, 0},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			monitor := Monitor{Status: test.statuses}
+			buckets := monitor.Buckets(time.Hour, 4, now)
+			var states []State
+			var pings []float64
+			for i, bucket := range buckets {
+				states = append(states, bucket.Status)
+				pings = append(pings, bucket.Ping)
+				if date := start.Add(time.Duration(i) * 15 * time.Minute); !time.Time(bucket.Date).Equal(date) {
+					t.Errorf("bucket %d starts at %s, want %s", i, time.Time(bucket.Date), date)
+				}
+			}
+			if !reflect.DeepEqual(states, test.want) || !reflect.DeepEqual(pings, test.pings) {
+				t.Errorf("Buckets() = %v %v, want %v %v", states, pings, test.want, test.pings)
+			}
+		})
+	}
+
+	// A window that cannot be split keeps the beats as they are
+	monitor := Monitor{Status: []Status{at(1, OK, 0)}}
+	for _, count := range []int{0, -1, 61} {
+		if buckets := monitor.Buckets(60*time.Nanosecond, count, now); !reflect.DeepEqual(buckets, monitor.Status) {
+			t.Errorf("Buckets(60ns, %d) = %+v, want the beats", count, buckets)
+		}
+	}
+}
+
+func TestValidateWindow(t *testing.T) {
+	tests := []struct {
+		window  time.Duration
+		buckets int
+		valid   bool
+	}{
+		{0, 0, true},
+		{time.Hour, 0, true},
+		{time.Hour, 60, true},
+		{60 * time.Nanosecond, 60, true},
+		{-time.Hour, 0, false},
+		{0, 60, false},
+		{time.Hour, -1, false},
+		{59 * time.Nanosecond, 60, false},
+	}
+	for _, test := range tests {
+		config := Config{Window: test.window, WindowBuckets: test.buckets, Symbol: Symbol{Term: "🌡"}}
+		err := config.Validate()
+		invalid := err != nil && strings.Contains(err.Error(), "invalid window")
+		if invalid == test.valid {
+			t.Errorf("window %s, %d buckets: Validate() = %v, want valid %v", test.window, test.buckets, err, test.valid)
+		}
+	}
+}